@@ -0,0 +1,47 @@
+package jsonrpc
+
+// acceptedResult is the result returned by Accepted.
+type acceptedResult struct {
+	result interface{}
+}
+
+// Accepted wraps the result of a method that has accepted work to be
+// completed asynchronously, such as a job id, so that a single request made
+// over HTTP has status 202 Accepted rather than 200. The result is otherwise
+// rendered as if it had been returned directly, and batch responses still
+// have status 200.
+//
+//	func enqueue(ctx context.Context, params *enqueueParams) (interface{}, error) {
+//		id, err := queue.Push(ctx, params.Job)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return jsonrpc.Accepted(jsonrpc.M{"job_id": id}), nil
+//	}
+func Accepted(result interface{}) interface{} {
+	return acceptedResult{result}
+}
+
+// mapResult returns result with the value rendered as the result replaced by
+// fn, within any Accepted and PartialResult wrappers.
+func mapResult(result interface{}, fn func(interface{}) interface{}) interface{} {
+	switch r := result.(type) {
+	case acceptedResult:
+		r.result = mapResult(r.result, fn)
+		return r
+	case PartialResult:
+		r.Result = fn(r.Result)
+		return r
+	default:
+		return fn(result)
+	}
+}
+
+// splitAccepted returns the wrapped result of result, and true, if it was
+// returned by Accepted, or result and false otherwise.
+func splitAccepted(result interface{}) (interface{}, bool) {
+	if ar, ok := result.(acceptedResult); ok {
+		return ar.result, true
+	}
+	return result, false
+}
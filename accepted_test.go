@@ -0,0 +1,69 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestAccepted(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Enqueue": func(ctx context.Context) (interface{}, error) {
+			return jsonrpc.Accepted(jsonrpc.M{"job_id": "j1"}), nil
+		},
+		"Partial": func(ctx context.Context) (interface{}, error) {
+			return jsonrpc.Accepted(jsonrpc.PartialResult{
+				Result: jsonrpc.M{"job_id": "j2"},
+				Error:  jsonrpc.InvalidParams("some jobs were rejected"),
+			}), nil
+		},
+	})
+
+	t.Run("single", func(t *testing.T) {
+		resp := do(server, `{"id": 1, "method": "Enqueue"}`)
+		assert.Equal(t, resp.Result().StatusCode, 202)
+		assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": {"job_id": "j1"}}`)
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		resp := do(server, `{"id": 1, "method": "Partial"}`)
+		assert.Equal(t, resp.Result().StatusCode, 200)
+		assert.JSONEqual(t, resp.Body.String(), `{
+			"id": 1,
+			"result": {"job_id": "j2"},
+			"error": {"name": "invalid_params", "message": "some jobs were rejected"}
+		}`)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		resp := do(server, `[{"id": 1, "method": "Enqueue"}, {"id": 2, "method": "Enqueue"}]`)
+		assert.Equal(t, resp.Result().StatusCode, 200)
+		assert.JSONEqual(t, resp.Body.String(), `[
+			{"id": 1, "result": {"job_id": "j1"}},
+			{"id": 2, "result": {"job_id": "j1"}}
+		]`)
+	})
+
+	t.Run("time encoder", func(t *testing.T) {
+		server := jsonrpc.New()
+		server.TimeEncoder = func(t time.Time) interface{} { return t.Unix() }
+		server.Register(jsonrpc.Methods{
+			"Schedule": func(ctx context.Context) (interface{}, error) {
+				return jsonrpc.Accepted(time.Unix(60, 0)), nil
+			},
+		})
+		resp := do(server, `{"id": 1, "method": "Schedule"}`)
+		assert.Equal(t, resp.Result().StatusCode, 202)
+		assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": 60}`)
+	})
+
+	t.Run("dispatch", func(t *testing.T) {
+		b, err := server.Dispatch(context.Background(), []byte(`{"id": 1, "method": "Enqueue"}`))
+		assert.Must(t, err)
+		assert.JSONEqual(t, string(b), `{"id": 1, "result": {"job_id": "j1"}}`)
+	})
+}
@@ -0,0 +1,76 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+)
+
+// AuditRecord describes a single RPC call, as recorded by AuditMiddleware.
+type AuditRecord struct {
+	// ID is the id of the RPC request.
+	ID interface{}
+
+	// Method is the name of the method called.
+	Method string
+
+	// Params is the raw JSON params sent by the client, if any. If the params
+	// have secret fields, it is instead the JSON encoding of the params with
+	// those fields masked by RedactParams.
+	Params json.RawMessage
+
+	// Result is the JSON encoding of the result, if the call succeeded.
+	Result json.RawMessage
+
+	// Error is the JSON encoding of the error, as rendered to the client, if
+	// the call failed.
+	Error json.RawMessage
+}
+
+// AuditSink receives audit records from AuditMiddleware. Implementations are
+// responsible for handling their own failures, since the call has already
+// completed by the time it is recorded.
+type AuditSink interface {
+	Record(ctx context.Context, rec AuditRecord)
+}
+
+// AuditMiddleware returns middleware that records each call's method, params,
+// and result or error to sink.
+//
+// Results are encoded independently of the final response, so the recorded
+// result reflects what this middleware sees: the output of middleware
+// registered after it, but not before it.
+func AuditMiddleware(sink AuditSink) Middleware {
+	return func(next Next) Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			result, err := next(ctx, params)
+
+			rec := AuditRecord{
+				ID:     RequestIDFromContext(ctx),
+				Method: MethodFromContext(ctx),
+				Params: RawParamsFromContext(ctx),
+			}
+			if params != nil && hasSecrets(reflect.TypeOf(params)) {
+				rec.Params = auditJSON(RedactParams(params))
+			}
+			if err != nil {
+				rec.Error = auditJSON(translateError(err))
+			} else {
+				rec.Result = auditJSON(result)
+			}
+			sink.Record(ctx, rec)
+
+			return result, err
+		}
+	}
+}
+
+// auditJSON encodes v for an audit record. If v cannot be encoded, the
+// encoding error is recorded instead.
+func auditJSON(v interface{}) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(M{"audit_error": err.Error()})
+	}
+	return b
+}
@@ -0,0 +1,55 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+type auditLog []jsonrpc.AuditRecord
+
+func (l *auditLog) Record(ctx context.Context, rec jsonrpc.AuditRecord) {
+	*l = append(*l, rec)
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	var log auditLog
+	server := jsonrpc.New()
+	server.DumpErrors = true
+	server.Use(jsonrpc.AuditMiddleware(&log))
+	server.Register(jsonrpc.Methods{
+		"Transfer": func(ctx context.Context, amount int) (interface{}, error) {
+			return jsonrpc.M{"amount": amount}, nil
+		},
+		"Fail": func(ctx context.Context) (interface{}, error) {
+			return nil, errors.New("secret failure")
+		},
+		"Login": func(ctx context.Context, params credentials) (interface{}, error) {
+			return nil, nil
+		},
+	})
+
+	do(server, `[
+		{"id": 1, "method": "Transfer", "params": 100},
+		{"id": "b", "method": "Fail"},
+		{"id": 3, "method": "Login", "params": {"username": "amy", "password": "hunter2"}}
+	]`)
+
+	assert.Equal(t, len(log), 3)
+	assert.Equal(t, log[0].ID, float64(1))
+	assert.Equal(t, log[0].Method, "Transfer")
+	assert.Equal(t, string(log[0].Params), `100`)
+	assert.Equal(t, string(log[0].Result), `{"amount":100}`)
+	assert.Equal(t, log[0].Error == nil, true)
+
+	assert.Equal(t, log[1].ID, "b")
+	assert.Equal(t, log[1].Method, "Fail")
+	assert.Equal(t, log[1].Params == nil, true)
+	assert.Equal(t, log[1].Result == nil, true)
+	assert.Equal(t, string(log[1].Error), `{"name":"internal_error","message":"internal error","retryable":true}`)
+
+	assert.JSONEqual(t, string(log[2].Params), `{"username": "amy", "password": "[REDACTED]", "otp": null, "pin": 0}`)
+}
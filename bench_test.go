@@ -0,0 +1,56 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func BenchmarkInvoke(b *testing.B) {
+	type params struct {
+		Name string `json:"name"`
+	}
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Hello": func(ctx context.Context, p *params) (interface{}, error) {
+			return jsonrpc.M{"message": "Hello, " + p.Name}, nil
+		},
+		"Now": func(ctx context.Context) (interface{}, error) {
+			return "now", nil
+		},
+	})
+
+	run := func(b *testing.B, body string) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+		}
+	}
+
+	b.Run("params", func(b *testing.B) {
+		run(b, `{"id": 1, "method": "Hello", "params": {"name": "Alice"}}`)
+	})
+	b.Run("no params", func(b *testing.B) {
+		run(b, `{"id": 1, "method": "Now"}`)
+	})
+	b.Run("batch", func(b *testing.B) {
+		run(b, `[
+			{"id": 1, "method": "Hello", "params": {"name": "Alice"}},
+			{"id": 2, "method": "Now"}
+		]`)
+	})
+	b.Run("large batch", func(b *testing.B) {
+		calls := make([]string, 50)
+		for i := range calls {
+			calls[i] = `{"id": ` + strconv.Itoa(i) + `, "method": "Now"}`
+		}
+		run(b, "["+strings.Join(calls, ",")+"]")
+	})
+}
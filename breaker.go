@@ -0,0 +1,121 @@
+package jsonrpc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BreakerConfig configures CircuitBreakerMiddleware.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures after which the
+	// breaker for a method opens. Defaults to 5.
+	FailureThreshold int
+
+	// ResetTimeout is how long a breaker stays open before a single trial
+	// call is let through. Defaults to 30 seconds.
+	ResetTimeout time.Duration
+
+	// FailureNames lists the RPCError names that count as failures. Errors
+	// that are not RPCErrors are treated as "internal_error". Defaults to
+	// []string{"internal_error"}.
+	FailureNames []string
+}
+
+// CircuitBreakerMiddleware returns middleware that fails fast when a method
+// keeps failing. Each method has its own breaker, which opens after
+// FailureThreshold consecutive failures. While open, calls return a
+// service_unavailable error without invoking the method. After ResetTimeout,
+// one trial call is let through: if it succeeds the breaker closes, otherwise
+// it opens again. A call that panics counts as a failure.
+func CircuitBreakerMiddleware(cfg BreakerConfig) Middleware {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.ResetTimeout <= 0 {
+		cfg.ResetTimeout = 30 * time.Second
+	}
+	if cfg.FailureNames == nil {
+		cfg.FailureNames = []string{"internal_error"}
+	}
+	failures := make(map[string]bool, len(cfg.FailureNames))
+	for _, name := range cfg.FailureNames {
+		failures[name] = true
+	}
+
+	var (
+		mu       sync.Mutex
+		breakers = make(map[string]*breaker)
+	)
+	get := func(method string) *breaker {
+		mu.Lock()
+		defer mu.Unlock()
+		b, ok := breakers[method]
+		if !ok {
+			b = &breaker{}
+			breakers[method] = b
+		}
+		return b
+	}
+
+	return func(next Next) Next {
+		return func(ctx context.Context, params interface{}) (result interface{}, err error) {
+			method := MethodFromContext(ctx)
+			b := get(method)
+			clock := ClockFromContext(ctx)
+			if !b.allow(clock.Now(), cfg.ResetTimeout) {
+				return nil, ServiceUnavailable("circuit open for method: %s", method)
+			}
+			// Record the outcome even if the method panics, counting the
+			// panic as a failure, so that a trial call cannot leave the
+			// breaker stuck open.
+			normal := false
+			defer func() {
+				failed := !normal || err != nil && failures[translateError(err).Name]
+				b.record(failed, clock.Now(), cfg.FailureThreshold)
+			}()
+			result, err = next(ctx, params)
+			normal = true
+			return result, err
+		}
+	}
+}
+
+// breaker tracks the state of the circuit for a single method.
+type breaker struct {
+	mu       sync.Mutex
+	failures int       // consecutive failures
+	openedAt time.Time // zero if closed
+	trial    bool      // trial call in flight
+}
+
+// allow reports whether a call may proceed at time now.
+func (b *breaker) allow(now time.Time, resetTimeout time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return true
+	}
+	if b.trial || now.Sub(b.openedAt) < resetTimeout {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// record updates the breaker with the outcome of a call.
+func (b *breaker) record(failed bool, now time.Time, threshold int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasTrial := b.trial
+	b.trial = false
+	if !failed {
+		b.failures = 0
+		b.openedAt = time.Time{}
+		return
+	}
+	b.failures++
+	if wasTrial || b.failures >= threshold {
+		b.openedAt = now
+	}
+}
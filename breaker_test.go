@@ -0,0 +1,122 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestCircuitBreakerMiddleware(t *testing.T) {
+	var (
+		calls int
+		fail  = true
+	)
+	clock := jsonrpc.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	server := jsonrpc.New()
+	server.Clock = clock
+	server.Use(jsonrpc.CircuitBreakerMiddleware(jsonrpc.BreakerConfig{
+		FailureThreshold: 2,
+		ResetTimeout:     20 * time.Millisecond,
+	}))
+	server.Register(jsonrpc.Methods{
+		"Downstream": func(ctx context.Context) (interface{}, error) {
+			calls++
+			if fail {
+				return nil, errors.New("downstream unavailable")
+			}
+			return "ok", nil
+		},
+		"NotFound": func(ctx context.Context) (interface{}, error) {
+			calls++
+			return nil, jsonrpc.NotFound("not found")
+		},
+	})
+
+	const open = `{
+		"id": 1,
+		"error": {"name": "service_unavailable", "message": "circuit open for method: Downstream", "retryable": true}
+	}`
+
+	// Failures open the breaker.
+	do(server, `{"id": 1, "method": "Downstream"}`)
+	do(server, `{"id": 1, "method": "Downstream"}`)
+	resp := do(server, `{"id": 1, "method": "Downstream"}`)
+	assert.JSONEqual(t, resp.Body.String(), open)
+	assert.Equal(t, calls, 2)
+
+	// A failed trial call opens it again.
+	clock.Advance(30 * time.Millisecond)
+	do(server, `{"id": 1, "method": "Downstream"}`)
+	assert.Equal(t, calls, 3)
+	resp = do(server, `{"id": 1, "method": "Downstream"}`)
+	assert.JSONEqual(t, resp.Body.String(), open)
+	assert.Equal(t, calls, 3)
+
+	// A successful trial call closes it.
+	clock.Advance(30 * time.Millisecond)
+	fail = false
+	resp = do(server, `{"id": 1, "method": "Downstream"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "ok"}`)
+	resp = do(server, `{"id": 1, "method": "Downstream"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "ok"}`)
+	assert.Equal(t, calls, 5)
+
+	// Errors not listed in FailureNames don't count.
+	calls = 0
+	for i := 0; i < 3; i++ {
+		do(server, `{"id": 1, "method": "NotFound"}`)
+	}
+	assert.Equal(t, calls, 3)
+}
+
+func TestCircuitBreakerMiddlewarePanic(t *testing.T) {
+	var (
+		calls int
+		fail  = true
+	)
+	clock := jsonrpc.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	server := jsonrpc.New()
+	server.Clock = clock
+	server.Use(jsonrpc.CircuitBreakerMiddleware(jsonrpc.BreakerConfig{
+		FailureThreshold: 2,
+		ResetTimeout:     20 * time.Millisecond,
+	}))
+	server.Register(jsonrpc.Methods{
+		"Downstream": func(ctx context.Context) (interface{}, error) {
+			calls++
+			if fail {
+				panic("downstream unavailable")
+			}
+			return "ok", nil
+		},
+	})
+
+	const open = `{
+		"id": 1,
+		"error": {"name": "service_unavailable", "message": "circuit open for method: Downstream", "retryable": true}
+	}`
+
+	// Panics open the breaker.
+	do(server, `{"id": 1, "method": "Downstream"}`)
+	do(server, `{"id": 1, "method": "Downstream"}`)
+	resp := do(server, `{"id": 1, "method": "Downstream"}`)
+	assert.JSONEqual(t, resp.Body.String(), open)
+	assert.Equal(t, calls, 2)
+
+	// A panicking trial call opens it again, rather than leaving the trial in
+	// flight.
+	clock.Advance(30 * time.Millisecond)
+	do(server, `{"id": 1, "method": "Downstream"}`)
+	assert.Equal(t, calls, 3)
+
+	// A later successful trial call closes it.
+	clock.Advance(30 * time.Millisecond)
+	fail = false
+	resp = do(server, `{"id": 1, "method": "Downstream"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "ok"}`)
+	assert.Equal(t, calls, 4)
+}
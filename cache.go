@@ -0,0 +1,140 @@
+package jsonrpc
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultCacheSize is the number of results cached when Handler.CacheSize is
+// zero.
+const defaultCacheSize = 1000
+
+// cachePolicy describes how the results of a method registered with
+// RegisterCached are cached.
+type cachePolicy struct {
+	ttl time.Duration
+	key func(params interface{}) string
+}
+
+// RegisterCached registers the set of methods owned by this group, like
+// Register, and caches their successful results for ttl. Results are cached
+// per method, keyed by key(params), or by the raw JSON params if key is nil,
+// so they must depend only on params; key is not called for methods that
+// don't accept params. Cached results are shared between
+// callers, and must not be modified.
+//
+// The cache is held in memory by the handler, and evicts the least recently
+// used results once it holds Handler.CacheSize of them. It sits inside all
+// middleware, so, for example, authentication still applies to cached
+// methods. The methods are registered with Meta.Cacheable set.
+func (g *Group) RegisterCached(methods Methods, ttl time.Duration, key func(params interface{}) string) {
+	cache := &cachePolicy{ttl: ttl, key: key}
+	if err := g.register(methods, nil, cache); err != nil {
+		panic(err.Error())
+	}
+}
+
+// RegisterCached registers the set of methods owned by this group, like
+// Register, and caches their successful results for ttl. See
+// Group.RegisterCached.
+func (h *Handler) RegisterCached(methods Methods, ttl time.Duration, key func(params interface{}) string) {
+	h.root.RegisterCached(methods, ttl, key)
+}
+
+// wrapCache returns a Next that serves the results of next for the method
+// with the given name from the handler's cache.
+func (h *Handler) wrapCache(next Next, name string, policy *cachePolicy) Next {
+	return func(ctx context.Context, params interface{}) (interface{}, error) {
+		key := string(RawParamsFromContext(ctx))
+		if policy.key != nil && params != nil {
+			key = policy.key(params)
+		}
+		key = name + "\x00" + key
+
+		cache := h.resultCache()
+		if result, ok := cache.get(key, h.clock().Now()); ok {
+			return result, nil
+		}
+		result, err := next(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		if !isRecvChan(result) {
+			cache.set(key, result, h.clock().Now().Add(policy.ttl))
+		}
+		return result, nil
+	}
+}
+
+// resultCache returns the handler's cache, creating it if needed.
+func (h *Handler) resultCache() *lruCache {
+	h.cacheOnce.Do(func() {
+		size := h.CacheSize
+		if size <= 0 {
+			size = defaultCacheSize
+		}
+		h.cache = newLRUCache(size)
+	})
+	return h.cache
+}
+
+// lruCache is a fixed-size cache of results with expiry times, which evicts
+// the least recently used results. It is safe for concurrent use.
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // of *cacheEntry, most recently used first
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	result  interface{}
+	expires time.Time
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// get returns the result cached under key, unless it has expired by now.
+func (c *lruCache) get(key string, now time.Time) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if !now.Before(entry.expires) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.result, true
+}
+
+// set caches result under key until expires, evicting the least recently
+// used result if the cache is full.
+func (c *lruCache) set(key string, result interface{}, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value = &cacheEntry{key: key, result: result, expires: expires}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, result: result, expires: expires})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
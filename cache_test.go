@@ -0,0 +1,81 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestRegisterCached(t *testing.T) {
+	type params struct {
+		ID    int    `json:"id"`
+		Trace string `json:"trace"`
+	}
+	var calls int
+	clock := jsonrpc.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	server := jsonrpc.New()
+	server.Clock = clock
+	server.CacheSize = 2
+	server.RegisterCached(jsonrpc.Methods{
+		"Get": func(ctx context.Context, p params) (interface{}, error) {
+			calls++
+			if p.ID < 0 {
+				return nil, jsonrpc.NotFound("not found")
+			}
+			return calls, nil
+		},
+		"Info": func(ctx context.Context) (interface{}, error) {
+			return jsonrpc.MethodInfoFromContext(ctx).Meta.Cacheable, nil
+		},
+	}, 50*time.Millisecond, func(p interface{}) string {
+		return string(rune('0' + p.(params).ID))
+	})
+
+	get := func(body string) string {
+		return do(server, `{"id": 1, "method": "Get", "params": `+body+`}`).Body.String()
+	}
+
+	// Results are keyed by the key function, ignoring other params.
+	assert.JSONEqual(t, get(`{"id": 1, "trace": "a"}`), `{"id": 1, "result": 1}`)
+	assert.JSONEqual(t, get(`{"id": 1, "trace": "b"}`), `{"id": 1, "result": 1}`)
+	assert.JSONEqual(t, get(`{"id": 2}`), `{"id": 1, "result": 2}`)
+
+	// Errors are not cached.
+	get(`{"id": -1}`)
+	get(`{"id": -1}`)
+	assert.Equal(t, calls, 4)
+
+	// The least recently used result is evicted.
+	assert.JSONEqual(t, get(`{"id": 1}`), `{"id": 1, "result": 1}`)
+	assert.JSONEqual(t, get(`{"id": 3}`), `{"id": 1, "result": 5}`)
+	assert.JSONEqual(t, get(`{"id": 1}`), `{"id": 1, "result": 1}`)
+	assert.JSONEqual(t, get(`{"id": 2}`), `{"id": 1, "result": 6}`)
+
+	// Results expire after the TTL.
+	clock.Advance(49 * time.Millisecond)
+	assert.JSONEqual(t, get(`{"id": 1}`), `{"id": 1, "result": 1}`)
+	clock.Advance(time.Millisecond)
+	assert.JSONEqual(t, get(`{"id": 1}`), `{"id": 1, "result": 7}`)
+
+	resp := do(server, `{"id": 1, "method": "Info"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": true}`)
+}
+
+func TestRegisterCachedRawParams(t *testing.T) {
+	var calls int
+	server := jsonrpc.New()
+	server.RegisterCached(jsonrpc.Methods{
+		"Echo": func(ctx context.Context, s string) (interface{}, error) {
+			calls++
+			return s, nil
+		},
+	}, time.Minute, nil)
+
+	do(server, `{"id": 1, "method": "Echo", "params": "a"}`)
+	do(server, `{"id": 2, "method": "Echo", "params": "a"}`)
+	do(server, `{"id": 3, "method": "Echo", "params": "b"}`)
+	assert.Equal(t, calls, 2)
+}
@@ -0,0 +1,72 @@
+package jsonrpc
+
+import "context"
+
+// CapabilitiesMethod is the name of the method registered by
+// RegisterCapabilities.
+const CapabilitiesMethod = "rpc.capabilities"
+
+// Capabilities describes the optional protocol features supported by a
+// Handler, so that clients may adapt to the server they are calling rather
+// than probing for features by trial and error.
+type Capabilities struct {
+	// Version is the version of the server, from Handler.ServerVersion.
+	Version string `json:"version,omitempty"`
+
+	// Batch indicates if requests may be sent in batches (AllowBatch).
+	Batch bool `json:"batch"`
+
+	// BatchStreaming indicates if the responses to a batch may be streamed
+	// as newline-delimited JSON (StreamBatches).
+	BatchStreaming bool `json:"batch_streaming"`
+
+	// Notifications indicates if requests may be sent without an id, that is,
+	// if any method is registered with Meta.Notification.
+	Notifications bool `json:"notifications"`
+
+	// Streaming indicates if methods may stream notifications to the client,
+	// that is, if any method accepts an Emit argument.
+	Streaming bool `json:"streaming"`
+
+	// FieldSelection indicates if clients may select the fields of results
+	// (AllowFieldSelection).
+	FieldSelection bool `json:"field_selection"`
+
+	// LenientNumbers indicates if numeric params may be sent as strings
+	// (LenientNumbers).
+	LenientNumbers bool `json:"lenient_numbers"`
+}
+
+// Capabilities returns the capabilities of the handler, as currently
+// configured and with the methods currently registered.
+func (h *Handler) Capabilities() Capabilities {
+	c := Capabilities{
+		Version:        h.ServerVersion,
+		Batch:          h.AllowBatch,
+		BatchStreaming: h.AllowBatch && h.StreamBatches,
+		FieldSelection: h.AllowFieldSelection,
+		LenientNumbers: h.LenientNumbers,
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, m := range h.methods {
+		if m.info.Meta.Notification {
+			c.Notifications = true
+		}
+		if m.stream {
+			c.Streaming = true
+		}
+	}
+	return c
+}
+
+// RegisterCapabilities registers a method named rpc.capabilities with the
+// handler, which takes no params and returns the handler's Capabilities. Like
+// other methods, it is wrapped by the handler's middleware.
+func (h *Handler) RegisterCapabilities() {
+	h.Register(Methods{
+		CapabilitiesMethod: func(ctx context.Context) (interface{}, error) {
+			return h.Capabilities(), nil
+		},
+	})
+}
@@ -0,0 +1,64 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestCapabilities(t *testing.T) {
+	server := jsonrpc.New()
+	server.ServerVersion = "1.4.0"
+	server.AllowFieldSelection = true
+	server.RegisterCapabilities()
+	server.Register(jsonrpc.Methods{
+		"Count": func(ctx context.Context, n int, emit jsonrpc.Emit) (interface{}, error) {
+			return nil, nil
+		},
+	})
+	server.RegisterWithMeta(jsonrpc.Methods{
+		"Log": func(ctx context.Context, msg string) (interface{}, error) {
+			return nil, nil
+		},
+	}, map[string]jsonrpc.Meta{
+		"Log": {Notification: true},
+	})
+
+	resp := do(server, `{"id": 1, "method": "rpc.capabilities"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"result": {
+			"version": "1.4.0",
+			"batch": true,
+			"batch_streaming": false,
+			"notifications": true,
+			"streaming": true,
+			"field_selection": true,
+			"lenient_numbers": false
+		}
+	}`)
+
+	// Capabilities reflect the current configuration.
+	server.AllowBatch = false
+	server.StreamBatches = true
+	assert.Equal(t, server.Capabilities(), jsonrpc.Capabilities{
+		Version:        "1.4.0",
+		Notifications:  true,
+		Streaming:      true,
+		FieldSelection: true,
+	})
+
+	// Notifications and streaming are only supported if some method is.
+	server = jsonrpc.New()
+	server.RegisterCapabilities()
+	server.Register(jsonrpc.Methods{
+		"Hello": func(ctx context.Context) (interface{}, error) {
+			return "hello", nil
+		},
+	})
+	assert.Equal(t, server.Capabilities(), jsonrpc.Capabilities{
+		Batch: true,
+	})
+}
@@ -0,0 +1,55 @@
+package jsonrpc
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// MiddlewareChain returns the names of the middleware that wrap the named
+// method, in the order they are called: the middleware of the root group
+// first, and that of the method's own group last. Each middleware is
+// identified by the name of its function, such as
+// "example.com/pkg.LoggingMiddleware.func1" for a closure returned by
+// LoggingMiddleware. It returns nil if the method is not registered.
+//
+// MiddlewareChain is intended for tests that assert the middleware of a group
+// tree is applied in the intended order.
+func (h *Handler) MiddlewareChain(name string) []string {
+	m, ok := h.lookup(name)
+	if !ok {
+		return nil
+	}
+	var groups []*Group
+	for g := m.group; g != nil; g = g.parent {
+		groups = append(groups, g)
+	}
+	registered := m.Name
+	if m.mount != nil {
+		registered = strings.TrimPrefix(registered, *m.mount)
+	}
+	chain := []string{}
+	for i := len(groups) - 1; i >= 0; i-- {
+		for _, gm := range groups[i].middleware {
+			if gm.appliesTo(registered) {
+				chain = append(chain, funcName(gm.mw))
+			}
+		}
+	}
+	return chain
+}
+
+// funcName returns the name of the function fn, or its type if it is not a
+// function.
+func funcName(fn interface{}) string {
+	val := reflect.ValueOf(fn)
+	if val.Kind() != reflect.Func {
+		return fmt.Sprintf("%T", fn)
+	}
+	f := runtime.FuncForPC(val.Pointer())
+	if f == nil {
+		return ""
+	}
+	return f.Name()
+}
@@ -0,0 +1,49 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func passthrough(next jsonrpc.Next) jsonrpc.Next { return next }
+
+func logging(next jsonrpc.Next) jsonrpc.Next { return next }
+
+func auth(next jsonrpc.Next) jsonrpc.Next { return next }
+
+func TestMiddlewareChain(t *testing.T) {
+	noop := func(context.Context) (interface{}, error) { return nil, nil }
+	server := jsonrpc.New()
+	server.Use(logging, passthrough)
+	admin := server.Group()
+	admin.Use(auth)
+	g := admin.Group()
+	g.Use(passthrough)
+	g.UseFor("Other*", logging)
+
+	server.Register(jsonrpc.Methods{"Root": noop})
+	g.Register(jsonrpc.Methods{"Nested": noop, "OtherNested": noop})
+
+	const pkg = "github.com/deliveroo/jsonrpc-go_test."
+	assert.Equal(t, server.MiddlewareChain("Root"), []string{
+		pkg + "logging",
+		pkg + "passthrough",
+	})
+	assert.Equal(t, server.MiddlewareChain("Nested"), []string{
+		pkg + "logging",
+		pkg + "passthrough",
+		pkg + "auth",
+		pkg + "passthrough",
+	})
+	assert.Equal(t, server.MiddlewareChain("OtherNested"), []string{
+		pkg + "logging",
+		pkg + "passthrough",
+		pkg + "auth",
+		pkg + "passthrough",
+		pkg + "logging",
+	})
+	assert.Equal(t, server.MiddlewareChain("Missing"), []string(nil))
+}
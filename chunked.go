@@ -0,0 +1,91 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// isRecvChan reports whether v is a channel that values may be received from.
+func isRecvChan(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	return t != nil && t.Kind() == reflect.Chan && t.ChanDir()&reflect.RecvDir != 0
+}
+
+// receiveAll calls fn with each value received from ch until it is closed or
+// ctx is done, or fn returns false.
+func receiveAll(ctx context.Context, ch interface{}, fn func(v interface{}) bool) {
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)},
+	}
+	for {
+		chosen, v, ok := reflect.Select(cases)
+		if chosen == 0 || !ok || !fn(v.Interface()) {
+			return
+		}
+	}
+}
+
+// collectChan returns the values received from ch until it is closed or ctx
+// is done, for transports that cannot stream results.
+func (h *Handler) collectChan(ctx context.Context, ch interface{}) []interface{} {
+	values := []interface{}{}
+	receiveAll(ctx, ch, func(v interface{}) bool {
+		if h.TimeEncoder != nil {
+			v = encodeTimes(v, h.TimeEncoder)
+		}
+		values = append(values, v)
+		return true
+	})
+	return values
+}
+
+// serveChunked writes the response to req, whose result is a channel, as a
+// response whose result is a JSON array. Each value received from the channel
+// is written and flushed as it arrives, so the response is sent with chunked
+// encoding. If a value cannot be encoded, the array is ended early and an
+// internal_error is rendered alongside it.
+func (h *Handler) serveChunked(ctx context.Context, w http.ResponseWriter, req *request, resp *response) {
+	w.Header().Set("content-type", "application/json; charset=utf-8")
+	w.WriteHeader(200)
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	_, _ = io.WriteString(w, `{"result":[`)
+	flush()
+	n := 0
+	receiveAll(ctx, resp.Result, func(v interface{}) bool {
+		if h.TimeEncoder != nil {
+			v = encodeTimes(v, h.TimeEncoder)
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			resp.Error = h.prepareError(RequestFromContext(ctx), req.Method, InternalError(err))
+			return false
+		}
+		if n > 0 {
+			_, _ = io.WriteString(w, ",")
+		}
+		n++
+		_, _ = w.Write(b)
+		flush()
+		return true
+	})
+
+	// Render the remaining members of the response after the result.
+	tail, _ := json.Marshal(response{
+		Error:    resp.Error,
+		Warnings: resp.Warnings,
+		Meta:     resp.Meta,
+		ID:       resp.ID,
+	})
+	_, _ = io.WriteString(w, "],")
+	_, _ = w.Write(tail[1:])
+}
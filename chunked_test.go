@@ -0,0 +1,112 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+	"github.com/deliveroo/jsonrpc-go/jsonrpctest"
+)
+
+func TestChunked(t *testing.T) {
+	defer jsonrpctest.AssertNoLeaks(t)()
+
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Pages": func(ctx context.Context, n int) (interface{}, error) {
+			ch := make(chan interface{})
+			go func() {
+				defer close(ch)
+				for i := 1; i <= n; i++ {
+					select {
+					case ch <- jsonrpc.M{"page": i}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return (<-chan interface{})(ch), nil
+		},
+		"Empty": func(ctx context.Context) (interface{}, error) {
+			jsonrpc.AddWarning(ctx, "no pages")
+			ch := make(chan int)
+			close(ch)
+			return ch, nil
+		},
+		"Invalid": func(ctx context.Context) (interface{}, error) {
+			ch := make(chan interface{}, 2)
+			ch <- 1
+			ch <- func() {}
+			close(ch)
+			return ch, nil
+		},
+	})
+
+	t.Run("single", func(t *testing.T) {
+		resp := do(server, `{"id": 1, "method": "Pages", "params": 3}`)
+		assert.Equal(t, resp.Result().StatusCode, 200)
+		assert.Equal(t, resp.Header().Get("content-type"), "application/json; charset=utf-8")
+		assert.Equal(t, resp.Flushed, true)
+		assert.Equal(t, resp.Body.String(), `{"result":[{"page":1},{"page":2},{"page":3}],"id":1}`)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		resp := do(server, `{"id": 1, "method": "Empty"}`)
+		assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": [], "warnings": ["no pages"]}`)
+	})
+
+	t.Run("encoding error", func(t *testing.T) {
+		resp := do(server, `{"id": 1, "method": "Invalid"}`)
+		assert.JSONEqual(t, resp.Body.String(), `{
+			"id": 1,
+			"result": [1],
+			"error": {"name": "internal_error", "message": "internal error", "retryable": true}
+		}`)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		resp := do(server, `[
+			{"id": 1, "method": "Empty"},
+			{"id": 2, "method": "Empty"}
+		]`)
+		assert.JSONEqual(t, resp.Body.String(), `[
+			{"id": 1, "error": {"name": "invalid_request", "message": "method Empty cannot be called in a batch"}, "warnings": ["no pages"]},
+			{"id": 2, "error": {"name": "invalid_request", "message": "method Empty cannot be called in a batch"}, "warnings": ["no pages"]}
+		]`)
+	})
+}
+
+func TestChunkedDisconnect(t *testing.T) {
+	defer jsonrpctest.AssertNoLeaks(t)()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Forever": func(ctx context.Context) (interface{}, error) {
+			ch := make(chan int)
+			go func() {
+				defer close(ch)
+				for i := 0; ; i++ {
+					if i == 3 {
+						cancel() // the client goes away
+					}
+					select {
+					case ch <- i:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return ch, nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id": 1, "method": "Forever"}`)).WithContext(ctx)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	assert.Equal(t, strings.HasPrefix(resp.Body.String(), `{"result":[0,1,2`), true)
+}
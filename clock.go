@@ -0,0 +1,72 @@
+package jsonrpc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock tells the current time. A Handler, and the middleware in this
+// package, read the time from Handler.Clock, which may be set to a FakeClock
+// so that time-dependent features, such as the TTLs of RegisterCached and the
+// reset timeouts of CircuitBreakerMiddleware, can be tested deterministically.
+// Context deadlines are unaffected.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// FakeClock is a Clock whose time only changes when it is set or advanced.
+// It is safe for concurrent use.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now implements the Clock interface.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set sets the time of the clock.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the time of the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// clock returns the handler's Clock.
+func (h *Handler) clock() Clock {
+	if h.Clock != nil {
+		return h.Clock
+	}
+	return realClock{}
+}
+
+// ClockFromContext extracts the Clock of the handler serving the current
+// request from the given context.Context, for use by middleware. It returns
+// a Clock that tells the real time if ctx did not originate from a Handler,
+// or its Clock is not set.
+func ClockFromContext(ctx context.Context) Clock {
+	if s := stateFromContext(ctx); s != nil && s.clock != nil {
+		return s.clock
+	}
+	return realClock{}
+}
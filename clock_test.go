@@ -0,0 +1,53 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := jsonrpc.NewFakeClock(start)
+	server := jsonrpc.New()
+	server.Clock = clock
+	server.ServerTiming = true
+	server.Register(jsonrpc.Methods{
+		"Now": func(ctx context.Context) (interface{}, error) {
+			return jsonrpc.ClockFromContext(ctx).Now(), nil
+		},
+		"Ticks": func(ctx context.Context, emit jsonrpc.Emit) (interface{}, error) {
+			if err := emit(jsonrpc.ClockFromContext(ctx).Now()); err != nil {
+				return nil, err
+			}
+			return jsonrpc.ClockFromContext(ctx).Now(), nil
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "Now"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "2020-01-01T00:00:00Z"}`)
+	assert.Equal(t, resp.Header().Get("Server-Timing"), "parse;dur=0.000, dispatch;dur=0.000, encode;dur=0.000")
+
+	clock.Advance(time.Hour)
+	resp = do(server, `{"id": 1, "method": "Now"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "2020-01-01T01:00:00Z"}`)
+
+	// Streaming methods use the clock too.
+	resp = do(server, `{"id": 1, "method": "Ticks"}`)
+	assert.Equal(t, resp.Body.String(), strings.Join([]string{
+		`{"method":"Ticks","params":"2020-01-01T01:00:00Z"}`,
+		`{"result":"2020-01-01T01:00:00Z","id":1}`,
+		``,
+	}, "\n"))
+
+	clock.Set(start)
+	assert.Equal(t, clock.Now(), start)
+
+	// Outside of a handler, the clock tells the real time.
+	now := jsonrpc.ClockFromContext(context.Background()).Now()
+	assert.Equal(t, time.Since(now) < time.Minute, true)
+}
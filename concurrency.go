@@ -0,0 +1,31 @@
+package jsonrpc
+
+import "context"
+
+// acquire reserves one of the handler's MaxConcurrency slots for a method
+// invocation, returning a function that releases it. If no slot is free, it
+// fails with a service_unavailable error, unless WaitForConcurrency is set,
+// in which case it waits until a slot is freed or ctx is done.
+func (h *Handler) acquire(ctx context.Context) (release func(), err error) {
+	if h.MaxConcurrency <= 0 {
+		return func() {}, nil
+	}
+	h.semOnce.Do(func() {
+		h.sem = make(chan struct{}, h.MaxConcurrency)
+	})
+	release = func() { <-h.sem }
+	select {
+	case h.sem <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if !h.WaitForConcurrency {
+		return nil, ServiceUnavailable("too many concurrent requests")
+	}
+	select {
+	case h.sem <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ServiceUnavailable("too many concurrent requests").Wrap(ctx.Err())
+	}
+}
@@ -0,0 +1,70 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+	"github.com/deliveroo/jsonrpc-go/jsonrpctest"
+)
+
+func TestMaxConcurrency(t *testing.T) {
+	defer jsonrpctest.AssertNoLeaks(t)()
+
+	var (
+		started = make(chan struct{})
+		unblock = make(chan struct{})
+	)
+	server := jsonrpc.New()
+	server.MaxConcurrency = 1
+	server.Register(jsonrpc.Methods{
+		"Block": func(ctx context.Context) (interface{}, error) {
+			close(started)
+			<-unblock
+			return "ok", nil
+		},
+		"Ping": func(ctx context.Context) (interface{}, error) {
+			return "pong", nil
+		},
+	})
+
+	done := make(chan string)
+	go func() {
+		done <- do(server, `{"id": 1, "method": "Block"}`).Body.String()
+	}()
+	<-started
+
+	t.Run("reject", func(t *testing.T) {
+		resp := do(server, `{"id": 2, "method": "Ping"}`)
+		assert.JSONEqual(t, resp.Body.String(), `{
+			"id": 2,
+			"error": {"name": "service_unavailable", "message": "too many concurrent requests", "retryable": true}
+		}`)
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		server.WaitForConcurrency = true
+		defer func() { server.WaitForConcurrency = false }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id": 3, "method": "Ping"}`)).WithContext(ctx)
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, req)
+		assert.JSONEqual(t, resp.Body.String(), `{
+			"id": 3,
+			"error": {"name": "service_unavailable", "message": "too many concurrent requests", "retryable": true}
+		}`)
+	})
+
+	close(unblock)
+	assert.JSONEqual(t, <-done, `{"id": 1, "result": "ok"}`)
+
+	resp := do(server, `{"id": 4, "method": "Ping"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 4, "result": "pong"}`)
+}
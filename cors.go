@@ -0,0 +1,107 @@
+package jsonrpc
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures the Cross-Origin Resource Sharing headers of a
+// Handler, allowing browser clients on other origins to call it.
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed to make requests, such as
+	// "https://dashboard.example.com". "*" allows any origin.
+	AllowedOrigins []string
+
+	// AllowedMethods lists the HTTP methods allowed in requests. Defaults to
+	// []string{"POST"}.
+	AllowedMethods []string
+
+	// AllowedHeaders lists the request headers allowed in requests. Defaults
+	// to Content-Type and the request headers read by this package:
+	// Accept-Language, Idempotency-Key, If-Match, X-Dry-Run, X-Fields, X-Nonce
+	// and X-Request-ID. Headers read by the application, such as Authorization or
+	// those listed in Handler.ExperimentHeaders, must be added explicitly.
+	AllowedHeaders []string
+
+	// ExposedHeaders lists the response headers that clients may read.
+	// Defaults to the response headers set by this package: ETag, Location,
+	// Retry-After, Server-Timing and X-Request-ID.
+	ExposedHeaders []string
+
+	// MaxAge is how long the result of a preflight request may be cached by
+	// the client. Zero means the header is omitted.
+	MaxAge time.Duration
+}
+
+// handle sets the CORS headers of the response to r, and responds to
+// preflight requests. It reports whether r was a preflight request, in which
+// case the response has been written.
+func (c *CORSConfig) handle(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	if origin != "" && c.allowsOrigin(origin) {
+		header.Set("Access-Control-Allow-Origin", origin)
+		if !preflight && len(c.exposedHeaders()) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(c.exposedHeaders(), ", "))
+		}
+		if preflight && contains(c.methods(), r.Header.Get("Access-Control-Request-Method")) {
+			header.Set("Access-Control-Allow-Methods", strings.Join(c.methods(), ", "))
+			header.Set("Access-Control-Allow-Headers", strings.Join(c.headers(), ", "))
+			if c.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+			}
+		}
+	}
+	if preflight {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return preflight
+}
+
+func (c *CORSConfig) allowsOrigin(origin string) bool {
+	return contains(c.AllowedOrigins, "*") || contains(c.AllowedOrigins, origin)
+}
+
+func (c *CORSConfig) methods() []string {
+	if c.AllowedMethods == nil {
+		return []string{http.MethodPost}
+	}
+	return c.AllowedMethods
+}
+
+func (c *CORSConfig) headers() []string {
+	if c.AllowedHeaders == nil {
+		return []string{
+			"Content-Type",
+			"Accept-Language",
+			"Idempotency-Key",
+			"If-Match",
+			"X-Dry-Run",
+			"X-Fields",
+			"X-Nonce",
+			traceIDHeader,
+		}
+	}
+	return c.AllowedHeaders
+}
+
+func (c *CORSConfig) exposedHeaders() []string {
+	if c.ExposedHeaders == nil {
+		return []string{"ETag", "Location", "Retry-After", "Server-Timing", traceIDHeader}
+	}
+	return c.ExposedHeaders
+}
+
+// contains reports whether list contains s.
+func contains(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+	return false
+}
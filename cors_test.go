@@ -0,0 +1,91 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestCORS(t *testing.T) {
+	server := jsonrpc.New()
+	server.CORS = &jsonrpc.CORSConfig{
+		AllowedOrigins: []string{"https://dashboard.example.com"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		MaxAge:         10 * time.Minute,
+	}
+	server.Register(jsonrpc.Methods{
+		"Ping": func(ctx context.Context) (interface{}, error) {
+			return "pong", nil
+		},
+	})
+	send := func(method, origin, requestMethod string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", strings.NewReader(`{"id": 1, "method": "Ping"}`))
+		req.Header.Set("Origin", origin)
+		if requestMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", requestMethod)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("preflight", func(t *testing.T) {
+		resp := send(http.MethodOptions, "https://dashboard.example.com", "POST")
+		assert.Equal(t, resp.Code, 204)
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Origin"), "https://dashboard.example.com")
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Methods"), "POST")
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Headers"), "Content-Type, Authorization")
+		assert.Equal(t, resp.Header().Get("Access-Control-Max-Age"), "600")
+		assert.Equal(t, resp.Header().Get("Vary"), "Origin")
+		assert.Equal(t, resp.Body.String(), "")
+	})
+
+	t.Run("preflight disallowed method", func(t *testing.T) {
+		resp := send(http.MethodOptions, "https://dashboard.example.com", "DELETE")
+		assert.Equal(t, resp.Code, 204)
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Methods"), "")
+	})
+
+	t.Run("preflight disallowed origin", func(t *testing.T) {
+		resp := send(http.MethodOptions, "https://evil.example.com", "POST")
+		assert.Equal(t, resp.Code, 204)
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Origin"), "")
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Methods"), "")
+	})
+
+	t.Run("request", func(t *testing.T) {
+		resp := send(http.MethodPost, "https://dashboard.example.com", "")
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Origin"), "https://dashboard.example.com")
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Methods"), "")
+		assert.Equal(t, resp.Header().Get("Access-Control-Expose-Headers"), "ETag, Location, Retry-After, Server-Timing, X-Request-ID")
+		assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "pong"}`)
+	})
+
+	t.Run("request disallowed origin", func(t *testing.T) {
+		resp := send(http.MethodPost, "https://evil.example.com", "")
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Origin"), "")
+		assert.Equal(t, resp.Header().Get("Access-Control-Expose-Headers"), "")
+	})
+
+	t.Run("default headers", func(t *testing.T) {
+		allowed := server.CORS.AllowedHeaders
+		server.CORS.AllowedHeaders = nil
+		defer func() { server.CORS.AllowedHeaders = allowed }()
+		resp := send(http.MethodOptions, "https://dashboard.example.com", "POST")
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Headers"),
+			"Content-Type, Accept-Language, Idempotency-Key, If-Match, X-Dry-Run, X-Fields, X-Nonce, X-Request-ID")
+		assert.Equal(t, resp.Header().Get("Access-Control-Expose-Headers"), "")
+	})
+
+	t.Run("any origin", func(t *testing.T) {
+		server.CORS.AllowedOrigins = []string{"*"}
+		resp := send(http.MethodPost, "https://other.example.com", "")
+		assert.Equal(t, resp.Header().Get("Access-Control-Allow-Origin"), "https://other.example.com")
+	})
+}
@@ -0,0 +1,53 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// DebugDump returns a human-readable listing of the registered methods, in
+// registration order, for diagnostics. For each method it lists the type of
+// its params, the group it was registered with, and the number of middleware
+// that wrap it. Groups are identified by their path from the handler, such as
+// "root/1/3", where subgroups are numbered in order of creation.
+//
+// For example:
+//
+//	#  METHOD  PARAMS             GROUP   MIDDLEWARE
+//	1  Hello   string             root    1
+//	2  Login   *main.loginParams  root/1  2
+func (h *Handler) DebugDump() string {
+	h.mu.RLock()
+	methods := make([]method, 0, len(h.methods))
+	for _, m := range h.methods {
+		methods = append(methods, m)
+	}
+	h.mu.RUnlock()
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i].seq < methods[j].seq
+	})
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tMETHOD\tPARAMS\tGROUP\tMIDDLEWARE")
+	for i, m := range methods {
+		params := "-"
+		if m.paramsType != nil {
+			params = m.paramsType.String()
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\n", i+1, m.Name, params, m.group.path(), m.depth)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// path returns the path of g from the handler's root group, e.g. "root/1/3".
+func (g *Group) path() string {
+	if g.parent == nil {
+		return "root"
+	}
+	return g.parent.path() + "/" + strconv.Itoa(g.id)
+}
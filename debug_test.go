@@ -0,0 +1,34 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestDebugDump(t *testing.T) {
+	type loginParams struct{}
+	passthrough := func(next jsonrpc.Next) jsonrpc.Next { return next }
+
+	server := jsonrpc.New()
+	server.Use(passthrough)
+	_ = server.Group()
+	auth := server.Group()
+	auth.Use(passthrough)
+	server.Register(jsonrpc.Methods{
+		"Hello": func(ctx context.Context, name string) (interface{}, error) { return nil, nil },
+	})
+	auth.Register(jsonrpc.Methods{
+		"Logout": func(ctx context.Context) (interface{}, error) { return nil, nil },
+		"Login":  func(ctx context.Context, params *loginParams) (interface{}, error) { return nil, nil },
+	})
+	server.Override("Hello", func(ctx context.Context, name string) (interface{}, error) { return nil, nil })
+
+	assert.Equal(t, server.DebugDump(), ""+
+		"#  METHOD  PARAMS                     GROUP   MIDDLEWARE\n"+
+		"1  Hello   string                     root    1\n"+
+		"2  Login   *jsonrpc_test.loginParams  root/2  2\n"+
+		"3  Logout  -                          root/2  2\n")
+}
@@ -0,0 +1,253 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// countingReader counts the bytes read from r, and records the first error
+// other than io.EOF.
+type countingReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if err != nil && err != io.EOF && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// peekByte returns the first non-whitespace byte of r, without consuming it.
+func peekByte(r *bufio.Reader) (byte, error) {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !isSpace(c) {
+			return c, r.UnreadByte()
+		}
+	}
+}
+
+// isSpace reports whether c is JSON whitespace.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// decodeRequest decodes a single request from r as it is read, rather than
+// buffering the whole body first, so that large requests are only held in
+// memory once. Errors match those of parseBody.
+func decodeRequest(r io.Reader) (*request, error) {
+	cr := &countingReader{r: r}
+	dec := json.NewDecoder(cr)
+	var req request
+	err := dec.Decode(&req)
+	if cr.err != nil {
+		return nil, InvalidRequest("could not read body").Wrap(cr.err)
+	}
+	if err == io.ErrUnexpectedEOF {
+		return nil, Error("parse_error", "cannot parse request: offset %d: unexpected end of JSON input", cr.n).Wrap(err)
+	}
+	if err != nil {
+		return nil, ParseError(err, "cannot parse request")
+	}
+	if err := expectEOF(dec, cr); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// decodeBatchStrict decodes the batch read from r element by element, failing
+// at the first element that is not a well-formed request, without reading the
+// rest of the batch. Unlike decodeBatch, the error fails the whole batch.
+func decodeBatchStrict(r io.Reader) ([]*request, error) {
+	cr := &countingReader{r: r}
+	dec := json.NewDecoder(cr)
+	fail := func(err error, msg string) ([]*request, error) {
+		if cr.err != nil {
+			return nil, InvalidRequest("could not read body").Wrap(cr.err)
+		}
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return nil, Error("parse_error", "%s: offset %d: unexpected end of JSON input", msg, cr.n).Wrap(err)
+		}
+		return nil, ParseError(err, msg)
+	}
+	if _, err := dec.Token(); err != nil {
+		return fail(err, "cannot parse request")
+	}
+	var requests []*request
+	for i := 0; dec.More(); i++ {
+		req := new(request)
+		if err := dec.Decode(req); err != nil {
+			return fail(err, fmt.Sprintf("cannot parse batch element %d", i))
+		}
+		switch req.ID.(type) {
+		case nil, float64, string:
+		default:
+			return nil, InvalidRequest("invalid batch element %d: id must be number or string", i)
+		}
+		if req.Method == "" {
+			return nil, InvalidRequest("invalid batch element %d: method required", i)
+		}
+		requests = append(requests, req)
+	}
+	if _, err := dec.Token(); err != nil {
+		return fail(err, "cannot parse request")
+	}
+	if err := expectEOF(dec, cr); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// expectEOF returns an error if anything but whitespace follows the value
+// decoded by dec from r.
+func expectEOF(dec *json.Decoder, r io.Reader) error {
+	offset := dec.InputOffset()
+	rest := bufio.NewReader(io.MultiReader(dec.Buffered(), r))
+	for {
+		c, err := rest.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return InvalidRequest("could not read body").Wrap(err)
+		}
+		offset++
+		if !isSpace(c) {
+			return Error("parse_error", "cannot parse request: offset %d: invalid character %s after top-level value", offset, quoteChar(c))
+		}
+	}
+}
+
+// decodeBatch decodes each element of the batch in body individually, so that
+// an element that is not a valid request fails alone, with an error giving its
+// index, while the others are still invoked. If body is not valid JSON, it
+// cannot be split into elements, so the whole batch fails, with an error
+// giving the index of the element the syntax error was found in.
+func decodeBatch(body []byte) ([]*request, error) {
+	var elems []json.RawMessage
+	if err := json.Unmarshal(body, &elems); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			if i := malformedElement(body); i >= 0 {
+				return nil, ParseError(err, fmt.Sprintf("cannot parse batch element %d", i))
+			}
+		}
+		return nil, ParseError(err, "cannot parse request")
+	}
+	requests := make([]*request, len(elems))
+	for i, elem := range elems {
+		req := new(request)
+		if err := json.Unmarshal(elem, req); err != nil {
+			// Keep the id, if it could be decoded, to correlate the error.
+			id := req.ID
+			switch id.(type) {
+			case string, float64:
+			default:
+				id = nil
+			}
+			req = &request{
+				ID:  id,
+				err: ParseError(err, fmt.Sprintf("cannot parse batch element %d", i)),
+			}
+		}
+		requests[i] = req
+	}
+	return requests, nil
+}
+
+// malformedElement returns the index of the first element of the batch in
+// body that is not valid JSON, or -1 if body is not an array.
+func malformedElement(body []byte) int {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return -1
+	}
+	for i := 0; ; i++ {
+		var elem json.RawMessage
+		if !dec.More() {
+			return -1
+		}
+		if err := dec.Decode(&elem); err != nil {
+			return i
+		}
+	}
+}
+
+// quoteChar formats c as a quoted character, like encoding/json's errors.
+func quoteChar(c byte) string {
+	switch c {
+	case '\'':
+		return `'\''`
+	case '"':
+		return `'"'`
+	}
+	s := strconv.Quote(string(c))
+	return "'" + s[1:len(s)-1] + "'"
+}
+
+// errorID returns the id to respond with when raw cannot be parsed: the id
+// recovered from raw if EchoMalformedIDs is set, or nil.
+func (h *Handler) errorID(raw []byte) interface{} {
+	if !h.EchoMalformedIDs {
+		return nil
+	}
+	return recoverID(raw)
+}
+
+// recoverID returns the id of the single request encoded in raw, as far as
+// it can be parsed, or nil if the id cannot be found before an error.
+func recoverID(raw []byte) interface{} {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+		if key != "id" {
+			if skipValue(dec) != nil {
+				return nil
+			}
+			continue
+		}
+		switch id, _ := dec.Token(); id.(type) {
+		case string, float64:
+			return id
+		}
+		return nil
+	}
+	return nil
+}
+
+// skipValue consumes the next value from dec.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
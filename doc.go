@@ -6,13 +6,63 @@
 //     func(ctx context.Context) (interface{}, error)           // no params
 //
 // If a method returns a value along with a nil error, the value will be
-// rendered to the client as JSON.
+// rendered to the client as JSON. A json.RawMessage result is embedded in the
+// response as-is, without being decoded first.
 //
 // If an error is returned, it will be sanitized and returned to the client as
 // json. Errors generated by a call to `jsonrpc.Error` will be rendered as-is to
 // the client. Any other errors will be obfuscated to the caller (unless
 // `DumpErrors` is enabled).
 //
+// Responses to a batch are always returned in the same order as its requests,
+// so clients that ignore ids may correlate responses by position. A batch of
+// one request is answered with an array of one response. Requests in a batch
+// without an id are notifications: they are invoked, but their responses are
+// omitted, and a batch of only notifications is answered with 204 No Content.
+//
+// The context passed to methods is derived from the HTTP request's context, so
+// it is cancelled if the client disconnects. Long-running methods should
+// respect ctx.Done(). Any requests in a batch that have not yet started when
+// the context is cancelled are not invoked, and return a request_cancelled
+// error instead.
+// Methods that fail after their context is done return a timeout error if
+// its deadline was exceeded, or a request_cancelled error otherwise.
+// The context's deadline is the effective deadline of the call: the earliest
+// of any set on the HTTP request's context, such as by http.TimeoutHandler,
+// and any set by middleware, such as for a per-method timeout. Methods may use
+// ctx.Deadline() to decide whether there is time left for more work.
+//
+// A Handler ignores the request path, so it may be mounted under any prefix of
+// a router such as http.ServeMux, chi or gorilla/mux, alongside other routes.
+// Requests must use the POST method; others are rejected with status 405,
+// except for CORS preflight requests.
+// It may also be wrapped by standard func(http.Handler) http.Handler
+// middleware: any values such middleware adds to the request's context are
+// visible to methods.
+//
+// Methods and middleware may access the request they are handling through
+// its context, with the following functions:
+//
+//	MethodFromContext       the name of the method called
+//	MethodInfoFromContext   the registered method's MethodInfo
+//	RequestIDFromContext    the id of the RPC request
+//	RawParamsFromContext    the raw JSON params of the RPC request
+//	BatchInfoFromContext    the position of the RPC request within its batch
+//	RequestFromContext      the HTTP request, if any
+//	TraceIDFromContext      the X-Request-ID of the HTTP request, if any
+//	ExperimentsFromContext  the experiments parsed from the HTTP request
+//	ClockFromContext        the handler's Clock
+//
+// and may affect the response with AddWarning, SetResponseMeta,
+// SetResponseHeader and SetETag, or memoize values with Once. The keys these
+// values are stored under are unexported, so they cannot collide with values
+// added by other packages, which should likewise define their keys with
+// unexported types, as recommended by context.WithValue.
+//
+// If Handler.AllowDryRun is set and a request is sent with the header
+// "X-Dry-Run: true", it is parsed and passed through all middleware as usual,
+// but no methods are invoked, and each result is null.
+//
 // Example:
 //
 //	var logger = log.New(os.Stderr, "server: ", 0)
@@ -41,7 +91,7 @@
 //				method := jsonrpc.MethodFromContext(ctx)
 //				start := time.Now()
 //				defer func() {
-//					logger.Printf("%s (%v)\n", method, time.Since(start))
+//					logger.Printf("%s %+v (%v)\n", method, jsonrpc.RedactParams(params), time.Since(start))
 //				}()
 //				return next(ctx, params)
 //			}
@@ -0,0 +1,58 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// RegisterResultEncoder registers fn to encode method results of type t as
+// JSON, in place of their MarshalJSON method or the default encoding, such as
+// for third-party types whose encoding cannot be changed. It applies to the
+// result returned by a method, including within a PartialResult or Accepted,
+// but not to values nested within the result, or received from a channel.
+//
+// If fn fails, or returns invalid JSON, the method fails with an
+// internal_error. Registering another encoder for t replaces the first.
+func (h *Handler) RegisterResultEncoder(t reflect.Type, fn func(v interface{}) ([]byte, error)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Copy the encoders, since they're read without holding the lock.
+	encoders := make(map[reflect.Type]func(interface{}) ([]byte, error), len(h.encoders)+1)
+	for t, fn := range h.encoders {
+		encoders[t] = fn
+	}
+	encoders[t] = fn
+	h.encoders = encoders
+}
+
+// encodeResult returns result with its value encoded by the encoder
+// registered for its type, if any.
+func (h *Handler) encodeResult(result interface{}) (interface{}, error) {
+	h.mu.RLock()
+	encoders := h.encoders
+	h.mu.RUnlock()
+	if len(encoders) == 0 {
+		return result, nil
+	}
+	var err error
+	result = mapResult(result, func(v interface{}) interface{} {
+		fn, ok := encoders[reflect.TypeOf(v)]
+		if !ok {
+			return v
+		}
+		b, encErr := fn(v)
+		switch {
+		case encErr != nil:
+			err = encErr
+		case !json.Valid(b):
+			err = fmt.Errorf("result encoder for %T returned invalid JSON", v)
+		}
+		return json.RawMessage(b)
+	})
+	if err != nil {
+		return nil, InternalError(err)
+	}
+	return result, nil
+}
@@ -0,0 +1,67 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+type money struct {
+	Cents    int
+	Currency string
+}
+
+func (m money) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"%d %s"`, m.Cents, m.Currency)), nil
+}
+
+func TestRegisterResultEncoder(t *testing.T) {
+	server := jsonrpc.New()
+	server.RegisterResultEncoder(reflect.TypeOf(money{}), func(v interface{}) ([]byte, error) {
+		m := v.(money)
+		return []byte(fmt.Sprintf(`{"amount": %d, "currency": %q}`, m.Cents, m.Currency)), nil
+	})
+	server.RegisterResultEncoder(reflect.TypeOf(errors.New("")), func(v interface{}) ([]byte, error) {
+		return nil, errors.New("cannot encode errors")
+	})
+	server.RegisterResultEncoder(reflect.TypeOf(0), func(v interface{}) ([]byte, error) {
+		return []byte(`{`), nil
+	})
+	server.Register(jsonrpc.Methods{
+		"Price": func(ctx context.Context) (interface{}, error) {
+			return money{150, "GBP"}, nil
+		},
+		"Prices": func(ctx context.Context) (interface{}, error) {
+			return []money{{150, "GBP"}}, nil
+		},
+		"Partial": func(ctx context.Context) (interface{}, error) {
+			return jsonrpc.PartialResult{Result: money{1, "EUR"}, Error: jsonrpc.NotFound("no tax")}, nil
+		},
+		"Failing": func(ctx context.Context) (interface{}, error) {
+			return errors.New("oops"), nil
+		},
+		"Invalid": func(ctx context.Context) (interface{}, error) {
+			return 1, nil
+		},
+	})
+
+	resp := do(server, `[
+		{"id": 1, "method": "Price"},
+		{"id": 2, "method": "Prices"},
+		{"id": 3, "method": "Partial"},
+		{"id": 4, "method": "Failing"},
+		{"id": 5, "method": "Invalid"}
+	]`)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{"id": 1, "result": {"amount": 150, "currency": "GBP"}},
+		{"id": 2, "result": ["150 GBP"]},
+		{"id": 3, "result": {"amount": 1, "currency": "EUR"}, "error": {"name": "not_found", "message": "no tax"}},
+		{"id": 4, "error": {"name": "internal_error", "message": "internal error", "retryable": true}},
+		{"id": 5, "error": {"name": "internal_error", "message": "internal error", "retryable": true}}
+	]`)
+}
@@ -0,0 +1,91 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ResponseView is a read-only view of a response, passed to
+// Handler.EnvelopeMarshaler and Handler.AfterResponse.
+type ResponseView struct {
+	resp *response
+}
+
+// ID returns the id of the request the response is to, or nil.
+func (v ResponseView) ID() interface{} { return v.resp.ID }
+
+// Result returns the result of the method, or nil if it failed.
+func (v ResponseView) Result() interface{} { return v.resp.Result }
+
+// Error returns the error returned by the method, or nil if it succeeded.
+// It should not be modified.
+func (v ResponseView) Error() *RPCError { return v.resp.Error }
+
+// Warnings returns the warnings added with AddWarning, if any.
+func (v ResponseView) Warnings() []string { return v.resp.Warnings }
+
+// Meta returns the metadata of the response, if any.
+func (v ResponseView) Meta() M { return v.resp.Meta }
+
+// marshalResponse encodes resp with the EnvelopeMarshaler, if set.
+func (h *Handler) marshalResponse(resp *response) ([]byte, error) {
+	if h.EnvelopeMarshaler == nil {
+		return json.Marshal(resp)
+	}
+	return h.EnvelopeMarshaler(ResponseView{resp})
+}
+
+// marshalPayload encodes a single response, or an array of responses, with
+// the EnvelopeMarshaler, if set.
+func (h *Handler) marshalPayload(payload interface{}) ([]byte, error) {
+	if h.EnvelopeMarshaler == nil {
+		return json.Marshal(payload)
+	}
+	switch payload := payload.(type) {
+	case *response:
+		return h.marshalResponse(payload)
+	case []*response:
+		b := []byte{'['}
+		for i, resp := range payload {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			rb, err := h.marshalResponse(resp)
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, rb...)
+		}
+		return append(b, ']'), nil
+	}
+	return json.Marshal(payload)
+}
+
+// encodePayload is like marshalPayload, but encodes payload using a pooled
+// encoder, which must be released once sent.
+func (h *Handler) encodePayload(payload interface{}) (*encoder, error) {
+	if h.EnvelopeMarshaler == nil {
+		return encodeJSON(payload)
+	}
+	b, err := h.marshalPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	e := encoderPool.Get().(*encoder)
+	e.buf.Reset()
+	e.buf.Write(b)
+	e.buf.WriteByte('\n')
+	return e, nil
+}
+
+// sendResponse encodes resp, as by encodePayload, and writes it to w with the
+// given status.
+func (h *Handler) sendResponse(w http.ResponseWriter, status int, resp *response) {
+	e, err := h.encodePayload(resp)
+	if err != nil {
+		sendJSON(w, http.StatusInternalServerError, response{Error: InternalError(err)})
+		return
+	}
+	defer e.release()
+	e.send(w, status)
+}
@@ -0,0 +1,48 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestEnvelopeMarshaler(t *testing.T) {
+	server := jsonrpc.New()
+	server.EnvelopeMarshaler = func(resp jsonrpc.ResponseView) ([]byte, error) {
+		if err := resp.Error(); err != nil {
+			return json.Marshal(jsonrpc.M{"ok": false, "error": err.Message})
+		}
+		return json.Marshal(jsonrpc.M{"ok": true, "data": resp.Result()})
+	}
+	server.Register(jsonrpc.Methods{
+		"Hello": func(ctx context.Context, name string) (interface{}, error) {
+			return "Hello, " + name, nil
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "Hello", "params": "Alice"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"ok": true, "data": "Hello, Alice"}`)
+
+	resp = do(server, `[
+		{"id": 1, "method": "Hello", "params": "Alice"},
+		{"id": 2, "method": "Missing"}
+	]`)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{"ok": true, "data": "Hello, Alice"},
+		{"ok": false, "error": "method not found: Missing"}
+	]`)
+
+	resp = do(server, `{`)
+	assert.Equal(t, resp.Result().StatusCode, 400)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"ok": false,
+		"error": "cannot parse request: offset 1: unexpected end of JSON input"
+	}`)
+
+	got, err := server.Dispatch(context.Background(), []byte(`{"id": 1, "method": "Hello", "params": "Bob"}`))
+	assert.Must(t, err)
+	assert.JSONEqual(t, string(got), `{"ok": true, "data": "Hello, Bob"}`)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 )
 
 // Error creates an error that will be rendered directly to the client.
@@ -42,6 +43,25 @@ func MethodNotFound(method string) *RPCError {
 	return Error("method_not_found", "method not found: %s", method)
 }
 
+// MultiError combines several errors into one, such as for a validation
+// failure with several causes. Its data is an array of errs, so that clients
+// may handle each of them:
+//
+//	{
+//		"name": "multiple_errors",
+//		"message": "multiple errors",
+//		"data": [
+//			{"name": "invalid_params", "message": "name is required"},
+//			{"name": "invalid_params", "message": "email is invalid"}
+//		]
+//	}
+func MultiError(errs ...*RPCError) *RPCError {
+	if errs == nil {
+		errs = []*RPCError{}
+	}
+	return Error("multiple_errors", "multiple errors").Data(errs)
+}
+
 // NotFound indicates that a requested entity could not be found.
 func NotFound(msg string, args ...interface{}) *RPCError {
 	return Error("not_found", msg, args...)
@@ -57,11 +77,66 @@ func ParseError(err error, msg string) *RPCError {
 	return Error("parse_error", msg).Wrap(err)
 }
 
+// PreconditionFailed indicates that a precondition of the request, such as
+// its If-Match header, was not met.
+func PreconditionFailed(msg string, args ...interface{}) *RPCError {
+	return Error("precondition_failed", msg, args...)
+}
+
+// RateLimited indicates that the client has made too many requests, and
+// should retry after the given duration. For single (non-batch) requests, the
+// HTTP response will have a Retry-After header. The duration is also included
+// in the error data, in seconds, so that it is available to clients within a
+// batch.
+func RateLimited(retryAfter time.Duration) *RPCError {
+	return Error("rate_limited", "rate limit exceeded").
+		Data(M{"retry_after": retryAfterSeconds(retryAfter)}).
+		RetryAfter(retryAfter)
+}
+
+// Redirect indicates that the client should fetch the result from location.
+// For single (non-batch) requests, the HTTP response will have the given 3xx
+// status and a Location header. The location is also included in the error
+// data, so that it is available to clients within a batch.
+func Redirect(location string, status int) *RPCError {
+	if status < 300 || status > 399 {
+		panic(fmt.Sprintf("jsonrpc: invalid redirect status: %d", status))
+	}
+	e := Error("redirect", "see %s", location).Data(M{"location": location})
+	e.status = status
+	e.location = location
+	return e
+}
+
+// RequestCancelled indicates that the request was not processed because its
+// context was cancelled, typically because the client disconnected.
+func RequestCancelled(err error) *RPCError {
+	return Error("request_cancelled", "request cancelled").Wrap(err)
+}
+
+// ServiceUnavailable indicates that the server is temporarily unable to handle
+// the request, e.g. because a dependency is down.
+func ServiceUnavailable(msg string, args ...interface{}) *RPCError {
+	return Error("service_unavailable", msg, args...)
+}
+
+// Timeout indicates that the request was not completed because its context's
+// deadline was exceeded.
+func Timeout(err error) *RPCError {
+	return Error("timeout", "request timed out").Wrap(err)
+}
+
 // Unauthorized indicates the client must be authenticated.
 func Unauthorized(msg string, args ...interface{}) *RPCError {
 	return Error("unauthorized", msg, args...)
 }
 
+// UpgradeRequired indicates that the client must be upgraded to a newer
+// version before its requests are accepted.
+func UpgradeRequired(msg string, args ...interface{}) *RPCError {
+	return Error("upgrade_required", msg, args...)
+}
+
 // RPCError is an error that will be returned to the client. If it wraps an
 // underlying error, and DumpErrors is enabled on the server, the underlying
 // error will be returned under "details" as an array of strings (split on
@@ -81,18 +156,75 @@ type RPCError struct {
 	// Message is the human-readable message of the error.
 	Message string
 
-	data       interface{} // optional additional error info
-	dumpErrors bool        // should wrapped error be rendered?
-	wrapped    error       // optional underlying error
+	data       interface{}   // optional additional error info
+	dumpErrors bool          // should wrapped error be rendered?
+	method     string        // optional method name to render
+	wrapped    error         // optional underlying error
+	key        string        // optional message catalog key
+	keyArgs    []interface{} // arguments for the message catalog entry
+	status     int           // optional HTTP status for single requests
+	location   string        // optional Location header for single requests
+	retryAfter time.Duration // optional Retry-After header for single requests
+	retryable  *bool         // optional override of defaultRetryable
+}
+
+// defaultRetryable lists the names of errors that are transient, so that the
+// request that caused them may be retried.
+var defaultRetryable = map[string]bool{
+	"internal_error":      true,
+	"rate_limited":        true,
+	"service_unavailable": true,
+	"timeout":             true,
 }
 
 // Data sets additional information about the error. This may be a primitive or
-// a structured object.
+// a structured object. Errors are rendered as their message, and values that
+// cannot be marshaled to JSON as a string.
 func (e *RPCError) Data(data interface{}) *RPCError {
 	e.data = data
 	return e
 }
 
+// Key sets a message catalog key for the error. If the handler has a
+// Localizer, the key and args will be used to replace Message with a
+// translation in the client's preferred language.
+func (e *RPCError) Key(key string, args ...interface{}) *RPCError {
+	e.key = key
+	e.keyArgs = args
+	return e
+}
+
+// RetryAfter sets how long the client should wait before retrying the
+// request. For single (non-batch) requests, the HTTP response will have a
+// Retry-After header, in whole seconds.
+func (e *RPCError) RetryAfter(d time.Duration) *RPCError {
+	e.retryAfter = d
+	return e
+}
+
+// Retryable sets whether the client may retry the request that caused the
+// error, overriding the default for its name.
+func (e *RPCError) Retryable(retryable bool) *RPCError {
+	e.retryable = &retryable
+	return e
+}
+
+// IsRetryable reports whether the client may retry the request that caused
+// the error, as set by Retryable. By default, internal_error, rate_limited,
+// service_unavailable and timeout errors are retryable, and others are not.
+// Retryable errors are rendered with "retryable": true.
+func (e *RPCError) IsRetryable() bool {
+	if e.retryable != nil {
+		return *e.retryable
+	}
+	return defaultRetryable[e.Name]
+}
+
+// retryAfterSeconds returns d in whole seconds, rounded up.
+func retryAfterSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
 // Wrap sets the underlying error that caused this RPC error.
 func (e *RPCError) Wrap(err error) *RPCError {
 	e.wrapped = err
@@ -119,14 +251,18 @@ func (e *RPCError) Unwrap() error {
 // MarshalJSON implements the json.Marshaler interface.
 func (e *RPCError) MarshalJSON() ([]byte, error) {
 	var result struct {
-		Name    string      `json:"name"`
-		Message string      `json:"message"`
-		Data    interface{} `json:"data,omitempty"`
-		Details []string    `json:"details,omitempty"`
+		Name      string      `json:"name"`
+		Message   string      `json:"message"`
+		Method    string      `json:"method,omitempty"`
+		Retryable bool        `json:"retryable,omitempty"`
+		Data      interface{} `json:"data,omitempty"`
+		Details   []string    `json:"details,omitempty"`
 	}
 	result.Name = e.Name
 	result.Message = e.Message
-	result.Data = e.data
+	result.Method = e.method
+	result.Retryable = e.IsRetryable()
+	result.Data = marshalableData(e.data)
 	if e.dumpErrors && e.wrapped != nil {
 		s := fmt.Sprintf("%+v", e.wrapped)      // stringify
 		s = strings.Replace(s, "\t", "  ", -1)  // tabs to spaces
@@ -135,6 +271,65 @@ func (e *RPCError) MarshalJSON() ([]byte, error) {
 	return json.Marshal(result)
 }
 
+// marshalableData returns data in a form that can always be marshaled: errors
+// are rendered as their message, and other values that cannot be marshaled
+// as a string, formatted as by fmt.Sprint.
+func marshalableData(data interface{}) interface{} {
+	if data == nil {
+		return nil
+	}
+	if err, ok := data.(error); ok {
+		if _, ok := data.(json.Marshaler); !ok {
+			return err.Error()
+		}
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Sprint(data)
+	}
+	return json.RawMessage(b)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, allowing clients to
+// decode errors returned by a server. The error data is retained as raw JSON,
+// and may be decoded with DataAs.
+func (e *RPCError) UnmarshalJSON(b []byte) error {
+	var result struct {
+		Name      string          `json:"name"`
+		Message   string          `json:"message"`
+		Retryable bool            `json:"retryable"`
+		Data      json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return err
+	}
+	e.Name = result.Name
+	e.Message = result.Message
+	e.retryable = &result.Retryable
+	e.data = nil
+	if len(result.Data) > 0 && string(result.Data) != "null" {
+		e.data = result.Data
+	}
+	return nil
+}
+
+// DataAs decodes the error's data into target, which must be a pointer. It
+// works both for errors decoded by a client, and for errors whose data was set
+// with Data. If the error has no data, target is left unchanged.
+func (e *RPCError) DataAs(target interface{}) error {
+	if e.data == nil {
+		return nil
+	}
+	raw, ok := e.data.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(e.data); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(raw, target)
+}
+
 // translateError coerces err into an RPCError that can be marshaled directly
 // to the client.
 func translateError(err error) *RPCError {
@@ -0,0 +1,131 @@
+package jsonrpc_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestRPCErrorDataAs(t *testing.T) {
+	type validation struct {
+		Name string `json:"name"`
+	}
+
+	t.Run("decoded", func(t *testing.T) {
+		var e jsonrpc.RPCError
+		assert.Must(t, json.Unmarshal([]byte(`{
+			"name": "invalid_customer",
+			"message": "customer failed validation",
+			"data": {"name": "must be present"}
+		}`), &e))
+		assert.Equal(t, e.Name, "invalid_customer")
+		assert.Equal(t, e.Message, "customer failed validation")
+
+		var got validation
+		assert.Must(t, e.DataAs(&got))
+		assert.Equal(t, got, validation{Name: "must be present"})
+
+		b, err := json.Marshal(&e)
+		assert.Must(t, err)
+		assert.JSONEqual(t, string(b), `{
+			"name": "invalid_customer",
+			"message": "customer failed validation",
+			"data": {"name": "must be present"}
+		}`)
+	})
+
+	t.Run("server side", func(t *testing.T) {
+		e := jsonrpc.Error("invalid_customer", "customer failed validation").Data(jsonrpc.M{
+			"name": "must be present",
+		})
+		var got validation
+		assert.Must(t, e.DataAs(&got))
+		assert.Equal(t, got, validation{Name: "must be present"})
+	})
+
+	t.Run("no data", func(t *testing.T) {
+		var e jsonrpc.RPCError
+		assert.Must(t, json.Unmarshal([]byte(`{"name": "not_found", "message": "not found", "data": null}`), &e))
+		got := validation{Name: "unchanged"}
+		assert.Must(t, e.DataAs(&got))
+		assert.Equal(t, got, validation{Name: "unchanged"})
+	})
+}
+
+func TestRPCErrorRetryable(t *testing.T) {
+	assert.Equal(t, jsonrpc.ServiceUnavailable("down").IsRetryable(), true)
+	assert.Equal(t, jsonrpc.InvalidParams("bad").IsRetryable(), false)
+	assert.Equal(t, jsonrpc.InvalidParams("bad").Retryable(true).IsRetryable(), true)
+	assert.Equal(t, jsonrpc.InternalError(nil).Retryable(false).IsRetryable(), false)
+
+	b, err := json.Marshal(jsonrpc.Error("conflict", "try again").Retryable(true))
+	assert.Must(t, err)
+	assert.JSONEqual(t, string(b), `{"name": "conflict", "message": "try again", "retryable": true}`)
+
+	b, err = json.Marshal(jsonrpc.InternalError(nil).Retryable(false))
+	assert.Must(t, err)
+	assert.JSONEqual(t, string(b), `{"name": "internal_error", "message": "internal error"}`)
+
+	// Decoded errors are only retryable if the server said so.
+	var e jsonrpc.RPCError
+	assert.Must(t, json.Unmarshal([]byte(`{"name": "internal_error", "message": "internal error"}`), &e))
+	assert.Equal(t, e.IsRetryable(), false)
+	assert.Must(t, json.Unmarshal([]byte(`{"name": "conflict", "message": "try again", "retryable": true}`), &e))
+	assert.Equal(t, e.IsRetryable(), true)
+}
+
+func TestRPCErrorData(t *testing.T) {
+	marshalData := func(data interface{}) json.RawMessage {
+		b, err := json.Marshal(jsonrpc.Error("payment_failed", "payment failed").Data(data))
+		assert.Must(t, err)
+		var got struct{ Data json.RawMessage }
+		assert.Must(t, json.Unmarshal(b, &got))
+		return got.Data
+	}
+
+	got := marshalData(errors.New("card declined"))
+	assert.JSONEqual(t, string(got), `"card declined"`)
+
+	got = marshalData(jsonrpc.NotFound("card not found"))
+	assert.JSONEqual(t, string(got), `{"name": "not_found", "message": "card not found"}`)
+
+	got = marshalData(jsonrpc.M{"card": "visa"})
+	assert.JSONEqual(t, string(got), `{"card": "visa"}`)
+
+	var s string
+	assert.Must(t, json.Unmarshal(marshalData(jsonrpc.M{"ch": make(chan int)}), &s))
+	assert.Equal(t, strings.HasPrefix(s, "map[ch:0x"), true)
+}
+
+func TestMultiError(t *testing.T) {
+	e := jsonrpc.MultiError(
+		jsonrpc.InvalidParams("name is required"),
+		jsonrpc.InvalidParams("email is invalid").Data(jsonrpc.M{"field": "email"}),
+	)
+	b, err := json.Marshal(e)
+	assert.Must(t, err)
+	assert.JSONEqual(t, string(b), `{
+		"name": "multiple_errors",
+		"message": "multiple errors",
+		"data": [
+			{"name": "invalid_params", "message": "name is required"},
+			{"name": "invalid_params", "message": "email is invalid", "data": {"field": "email"}}
+		]
+	}`)
+
+	// Clients may decode the individual errors.
+	var decoded jsonrpc.RPCError
+	assert.Must(t, json.Unmarshal(b, &decoded))
+	var errs []*jsonrpc.RPCError
+	assert.Must(t, decoded.DataAs(&errs))
+	assert.Equal(t, len(errs), 2)
+	assert.Equal(t, errs[1].Message, "email is invalid")
+
+	b, err = json.Marshal(jsonrpc.MultiError())
+	assert.Must(t, err)
+	assert.JSONEqual(t, string(b), `{"name": "multiple_errors", "message": "multiple errors", "data": []}`)
+}
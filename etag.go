@@ -0,0 +1,39 @@
+package jsonrpc
+
+import (
+	"context"
+	"strings"
+)
+
+// IfMatch reports whether the If-Match header of the current HTTP request is
+// satisfied by etag, the current version of a resource. It is satisfied if
+// the header is absent, is "*", or lists etag. etag is the opaque tag without
+// quotes. As required for If-Match, weak tags never match.
+//
+// Methods performing optimistic concurrency control typically return a
+// PreconditionFailed error if IfMatch returns false.
+func IfMatch(ctx context.Context, etag string) bool {
+	r := RequestFromContext(ctx)
+	if r == nil {
+		return true
+	}
+	values := r.Header["If-Match"]
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || tag == `"`+etag+`"` {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetETag sets the ETag header of the HTTP response for the current request.
+// etag is the opaque tag without quotes.
+func SetETag(ctx context.Context, etag string) {
+	SetResponseHeader(ctx, "ETag", `"`+etag+`"`)
+}
@@ -0,0 +1,55 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestETag(t *testing.T) {
+	version := "v1"
+	server := jsonrpc.New()
+	server.ErrorStatusMode = jsonrpc.MapFromName
+	server.Register(jsonrpc.Methods{
+		"Update": func(ctx context.Context) (interface{}, error) {
+			if !jsonrpc.IfMatch(ctx, version) {
+				return nil, jsonrpc.PreconditionFailed("resource has changed")
+			}
+			version = "v2"
+			jsonrpc.SetETag(ctx, version)
+			return "ok", nil
+		},
+	})
+
+	doIfMatch := func(ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id": 1, "method": "Update"}`))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	resp := doIfMatch(`"v0", W/"v1"`)
+	assert.Equal(t, resp.Result().StatusCode, http.StatusPreconditionFailed)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {"name": "precondition_failed", "message": "resource has changed"}
+	}`)
+
+	resp = doIfMatch(`"v0", "v1"`)
+	assert.Equal(t, resp.Result().StatusCode, http.StatusOK)
+	assert.Equal(t, resp.Header().Get("ETag"), `"v2"`)
+
+	resp = doIfMatch(`*`)
+	assert.Equal(t, resp.Result().StatusCode, http.StatusOK)
+
+	resp = doIfMatch(``)
+	assert.Equal(t, resp.Result().StatusCode, http.StatusOK)
+}
@@ -0,0 +1,55 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Experiments holds the experiments enabled for a request, mapping each
+// experiment name to its value. Experiments without an explicit value map to
+// the empty string.
+type Experiments map[string]string
+
+// Enabled reports whether the named experiment is present.
+func (e Experiments) Enabled(name string) bool {
+	_, ok := e[name]
+	return ok
+}
+
+// ExperimentsFromContext extracts the experiments parsed from the headers
+// listed in Handler.ExperimentHeaders from the given context.Context. It
+// returns nil if there are none.
+func ExperimentsFromContext(ctx context.Context) Experiments {
+	e, _ := ctx.Value(contextKeyExperiments).(Experiments)
+	return e
+}
+
+// parseExperiments parses the given headers of r. Each header holds a
+// comma-separated list of experiments, either as a bare name or as a
+// name=value pair:
+//
+//	X-Experiment: new-checkout, variant=b
+//
+// If an experiment appears more than once, the last value wins.
+func parseExperiments(r *http.Request, headers []string) Experiments {
+	var result Experiments
+	for _, header := range headers {
+		for _, value := range r.Header[http.CanonicalHeaderKey(header)] {
+			for _, part := range strings.Split(value, ",") {
+				name, val := strings.TrimSpace(part), ""
+				if i := strings.Index(name, "="); i != -1 {
+					name, val = strings.TrimSpace(name[:i]), strings.TrimSpace(name[i+1:])
+				}
+				if name == "" {
+					continue
+				}
+				if result == nil {
+					result = make(Experiments)
+				}
+				result[name] = val
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,42 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestExperiments(t *testing.T) {
+	var got jsonrpc.Experiments
+	server := jsonrpc.New()
+	server.ExperimentHeaders = []string{"X-Experiment", "X-Other-Experiment"}
+	server.Register(jsonrpc.Methods{
+		"Do": func(ctx context.Context) (interface{}, error) {
+			got = jsonrpc.ExperimentsFromContext(ctx)
+			return nil, nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id": 1, "method": "Do"}`))
+	req.Header.Add("X-Experiment", "new-checkout, variant = b")
+	req.Header.Add("X-Experiment", "variant=c,,")
+	req.Header.Add("X-Other-Experiment", "fast-path")
+	req.Header.Add("X-Ignored", "ignored")
+	server.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, got, jsonrpc.Experiments{
+		"new-checkout": "",
+		"variant":      "c",
+		"fast-path":    "",
+	})
+	assert.Equal(t, got.Enabled("new-checkout"), true)
+	assert.Equal(t, got.Enabled("ignored"), false)
+
+	do(server, `{"id": 1, "method": "Do"}`)
+	assert.Equal(t, got, jsonrpc.Experiments(nil))
+}
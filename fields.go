@@ -0,0 +1,59 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// selectedFields returns the result fields requested by the client for req:
+// the request's "fields" member if present, otherwise the comma-separated
+// X-Fields header of r, if any. The member is decoded here, rather than with
+// the request, so that a malformed one fails only its own request.
+func selectedFields(r *http.Request, req *request) ([]string, error) {
+	if len(req.Fields) > 0 && string(req.Fields) != "null" {
+		var fields []string
+		if err := json.Unmarshal(req.Fields, &fields); err != nil {
+			return nil, InvalidParams("fields must be an array of strings")
+		}
+		return fields, nil
+	}
+	if r == nil {
+		return nil, nil
+	}
+	header := r.Header.Get("X-Fields")
+	if header == "" {
+		return nil, nil
+	}
+	var fields []string
+	for _, f := range strings.Split(header, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// filterFields returns result with only the given top-level fields. Results
+// that don't encode to a JSON object are returned unchanged, and unknown
+// fields are ignored.
+func filterFields(result interface{}, fields []string) interface{} {
+	if _, ok := asRawResponse(result); ok {
+		return result // not rendered as JSON
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		return result // let the response encoder report the error
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return result // not an object
+	}
+	filtered := make(map[string]json.RawMessage, len(fields))
+	for _, f := range fields {
+		if v, ok := obj[f]; ok {
+			filtered[f] = v
+		}
+	}
+	return filtered
+}
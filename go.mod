@@ -6,4 +6,5 @@ require (
 	github.com/deliveroo/assert-go v1.0.3
 	github.com/golangci/golangci-lint v1.25.0
 	github.com/google/go-cmp v0.3.0 // indirect
+	golang.org/x/sync v0.0.0-20220907140024-f12130a52804
 )
@@ -0,0 +1,26 @@
+package jsonrpc
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// IDGenerator generates ids for messages initiated by the server, such as the
+// notifications sent when Handler.IDGenerator is set. Ids must be unique for
+// the lifetime of a connection, and be a string or a number.
+type IDGenerator interface {
+	NextID() interface{}
+}
+
+// CounterIDGenerator is an IDGenerator that returns monotonically increasing
+// integers, starting at 1, as decimal strings, so that they survive a round
+// trip through clients that decode numbers as floats. The zero value is ready
+// to use, and it is safe for concurrent use.
+type CounterIDGenerator struct {
+	n uint64
+}
+
+// NextID implements the IDGenerator interface.
+func (g *CounterIDGenerator) NextID() interface{} {
+	return strconv.FormatUint(atomic.AddUint64(&g.n, 1), 10)
+}
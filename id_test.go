@@ -0,0 +1,41 @@
+package jsonrpc_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestCounterIDGenerator(t *testing.T) {
+	var g jsonrpc.IDGenerator = &jsonrpc.CounterIDGenerator{}
+	assert.Equal(t, g.NextID(), "1")
+	assert.Equal(t, g.NextID(), "2")
+}
+
+func TestCounterIDGeneratorConcurrent(t *testing.T) {
+	var (
+		g    jsonrpc.CounterIDGenerator
+		mu   sync.Mutex
+		seen = make(map[interface{}]bool)
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				id := g.NextID()
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("duplicate id %v", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, len(seen), 1000)
+}
@@ -0,0 +1,61 @@
+package jsonrpc
+
+import (
+	"context"
+	"strconv"
+)
+
+// IdempotencyStore stores the results of previously completed requests for
+// IdempotencyMiddleware.
+type IdempotencyStore interface {
+	// Get returns the result previously stored for the given method and
+	// idempotency key. If no result has been stored, ok is false.
+	Get(ctx context.Context, method, key string) (result interface{}, ok bool, err error)
+
+	// Set stores the result for the given method and idempotency key.
+	Set(ctx context.Context, method, key string, result interface{}) error
+}
+
+// IdempotencyMiddleware returns middleware that makes methods safe to retry.
+// If the HTTP request carries an Idempotency-Key header, and a result has
+// already been stored for that key and method, the stored result is returned
+// without invoking the method again.
+//
+// Only successful results are stored, so a request that failed may be retried
+// with the same key. Within a batch, the position of each request is appended
+// to the key so that repeated calls to the same method don't collide.
+func IdempotencyMiddleware(store IdempotencyStore) Middleware {
+	return func(next Next) Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			r := RequestFromContext(ctx)
+			if r == nil || inDryRun(ctx) {
+				return next(ctx, params)
+			}
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" {
+				return next(ctx, params)
+			}
+			if index, _, ok := BatchInfoFromContext(ctx); ok {
+				key += "/" + strconv.Itoa(index)
+			}
+
+			method := MethodFromContext(ctx)
+			result, ok, err := store.Get(ctx, method, key)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return result, nil
+			}
+
+			result, err = next(ctx, params)
+			if err != nil {
+				return nil, err
+			}
+			if err := store.Set(ctx, method, key, result); err != nil {
+				return nil, err
+			}
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+type memoryStore map[string]interface{}
+
+func (s memoryStore) Get(ctx context.Context, method, key string) (interface{}, bool, error) {
+	result, ok := s[method+":"+key]
+	return result, ok, nil
+}
+
+func (s memoryStore) Set(ctx context.Context, method, key string, result interface{}) error {
+	s[method+":"+key] = result
+	return nil
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	var calls int
+	store := memoryStore{}
+	server := jsonrpc.New()
+	server.Use(jsonrpc.IdempotencyMiddleware(store))
+	server.Register(jsonrpc.Methods{
+		"Create": func(ctx context.Context) (interface{}, error) {
+			calls++
+			return calls, nil
+		},
+		"Fail": func(ctx context.Context) (interface{}, error) {
+			calls++
+			return nil, errors.New("failed")
+		},
+	})
+
+	doWithKey := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("repeat key", func(t *testing.T) {
+		calls = 0
+		resp := doWithKey("a", `{"id": 1, "method": "Create"}`)
+		assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": 1}`)
+		resp = doWithKey("a", `{"id": 2, "method": "Create"}`)
+		assert.JSONEqual(t, resp.Body.String(), `{"id": 2, "result": 1}`)
+		assert.Equal(t, calls, 1)
+	})
+
+	t.Run("no key", func(t *testing.T) {
+		calls = 0
+		doWithKey("", `{"id": 1, "method": "Create"}`)
+		doWithKey("", `{"id": 1, "method": "Create"}`)
+		assert.Equal(t, calls, 2)
+	})
+
+	t.Run("errors are not stored", func(t *testing.T) {
+		calls = 0
+		doWithKey("b", `{"id": 1, "method": "Fail"}`)
+		doWithKey("b", `{"id": 1, "method": "Fail"}`)
+		assert.Equal(t, calls, 2)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		calls = 0
+		body := `[{"id": 1, "method": "Create"}, {"id": 2, "method": "Create"}]`
+		resp := doWithKey("c", body)
+		assert.JSONEqual(t, resp.Body.String(), `[{"id": 1, "result": 1}, {"id": 2, "result": 2}]`)
+		resp = doWithKey("c", body)
+		assert.JSONEqual(t, resp.Body.String(), `[{"id": 1, "result": 1}, {"id": 2, "result": 2}]`)
+		assert.Equal(t, calls, 2)
+	})
+}
@@ -0,0 +1,65 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Request is a request received from a client, passed to
+// Handler.RequestInterceptor, which may rewrite its method and params before
+// it is dispatched, and to Handler.AfterResponse.
+type Request struct {
+	// Method is the name of the method to call.
+	Method string
+
+	// Params holds the raw JSON params, or nil if none were sent.
+	Params json.RawMessage
+
+	id interface{}
+}
+
+// ID returns the id of the request: a float64 or string, or nil if absent.
+func (r *Request) ID() interface{} { return r.id }
+
+// intercept passes each of requests to the RequestInterceptor, if set,
+// applying any changes it makes, and recording any error it returns as the
+// response to the request.
+func (h *Handler) intercept(requests []*request) {
+	if h.RequestInterceptor == nil {
+		return
+	}
+	for _, req := range requests {
+		if req.err != nil {
+			continue
+		}
+		view := &Request{Method: req.Method, Params: req.Params, id: req.ID}
+		if err := h.RequestInterceptor(view); err != nil {
+			req.err = err
+			continue
+		}
+		req.Method, req.Params = view.Method, view.Params
+	}
+}
+
+// afterResponse passes requests, and their responses, to the AfterResponse
+// hook, if set. requests is nil if none were read.
+func (h *Handler) afterResponse(ctx context.Context, requests []*request, responses []*response) {
+	if h.AfterResponse == nil {
+		return
+	}
+	var reqs []*Request
+	if requests != nil {
+		reqs = make([]*Request, len(requests))
+		for i, req := range requests {
+			reqs[i] = &Request{Method: req.Method, Params: req.Params, id: req.ID}
+		}
+	}
+	var resps []ResponseView
+	if responses != nil {
+		resps = make([]ResponseView, len(responses))
+		for i, resp := range responses {
+			resps[i] = ResponseView{resp}
+		}
+	}
+	h.AfterResponse(ctx, reqs, resps)
+}
@@ -0,0 +1,128 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestRequestInterceptor(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Greet": func(ctx context.Context, params struct{ Name string }) (interface{}, error) {
+			return "hello " + params.Name, nil
+		},
+	})
+	var ids []interface{}
+	server.RequestInterceptor = func(req *jsonrpc.Request) error {
+		ids = append(ids, req.ID())
+		switch req.Method {
+		case "SayHello":
+			// Translate the deprecated method and its positional params.
+			var args []string
+			if err := json.Unmarshal(req.Params, &args); err != nil || len(args) != 1 {
+				return jsonrpc.InvalidParams("want [name]")
+			}
+			req.Method = "Greet"
+			req.Params, _ = json.Marshal(jsonrpc.M{"name": args[0]})
+		case "Removed":
+			return jsonrpc.MethodNotFound(req.Method)
+		}
+		return nil
+	}
+
+	t.Run("rewrite", func(t *testing.T) {
+		ids = nil
+		resp := do(server, `{"id": 1, "method": "SayHello", "params": ["bob"]}`)
+		assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "hello bob"}`)
+		assert.Equal(t, ids, []interface{}{float64(1)})
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		resp := do(server, `[
+			{"id": 1, "method": "Greet", "params": {"name": "amy"}},
+			{"id": 2, "method": "Removed"},
+			{"id": 3, "method": "SayHello", "params": []}
+		]`)
+		assert.JSONEqual(t, resp.Body.String(), `[
+			{"id": 1, "result": "hello amy"},
+			{"id": 2, "error": {"name": "method_not_found", "message": "method not found: Removed"}},
+			{"id": 3, "error": {"name": "invalid_params", "message": "want [name]"}}
+		]`)
+	})
+
+	t.Run("dispatch", func(t *testing.T) {
+		b, err := server.Dispatch(context.Background(), []byte(`{"id": "a", "method": "SayHello", "params": ["cat"]}`))
+		assert.Must(t, err)
+		assert.JSONEqual(t, string(b), `{"id": "a", "result": "hello cat"}`)
+	})
+}
+
+func TestAfterResponse(t *testing.T) {
+	server := jsonrpc.New()
+	server.CORS = &jsonrpc.CORSConfig{AllowedOrigins: []string{"*"}}
+	server.Register(jsonrpc.Methods{
+		"Echo": func(ctx context.Context, s string) (interface{}, error) {
+			return s, nil
+		},
+		"Fail": func(ctx context.Context) (interface{}, error) {
+			return nil, errors.New("boom")
+		},
+	})
+	var (
+		w     *httptest.ResponseRecorder
+		calls [][]string
+	)
+	server.AfterResponse = func(ctx context.Context, reqs []*jsonrpc.Request, resps []jsonrpc.ResponseView) {
+		// The response has already been written.
+		assert.Equal(t, w.Body.Len() > 0 || w.Code == http.StatusNoContent, true)
+		call := []string{}
+		for i, resp := range resps {
+			s := "<nil>"
+			if reqs != nil {
+				s = fmt.Sprintf("%v %s", reqs[i].ID(), reqs[i].Method)
+			}
+			if err := resp.Error(); err != nil {
+				s += " " + err.Name
+			} else {
+				s += fmt.Sprintf(" %v", resp.Result())
+			}
+			call = append(call, s)
+		}
+		calls = append(calls, call)
+	}
+	serve := func(r *http.Request) [][]string {
+		calls = nil
+		w = httptest.NewRecorder()
+		server.ServeHTTP(w, r)
+		return calls
+	}
+	post := func(body string) [][]string {
+		return serve(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	}
+
+	assert.Equal(t, post(`{"id": 1, "method": "Echo", "params": "a"}`), [][]string{{"1 Echo a"}})
+	assert.Equal(t, post(`{"id": 1, "method": "Fail"}`), [][]string{{"1 Fail internal_error"}})
+	assert.Equal(t, post(`{"id": 1,`), [][]string{{"<nil> parse_error"}})
+
+	// A batch is reported in a single call.
+	assert.Equal(t, post(`[
+		{"id": 1, "method": "Echo", "params": "a"},
+		{"id": 2, "method": "Missing"}
+	]`), [][]string{{"1 Echo a", "2 Missing method_not_found"}})
+
+	// So are requests rejected before the body is read.
+	assert.Equal(t, serve(httptest.NewRequest(http.MethodGet, "/", nil)), [][]string{{"<nil> invalid_request"}})
+	preflight := httptest.NewRequest(http.MethodOptions, "/", nil)
+	preflight.Header.Set("Origin", "https://example.com")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	assert.Equal(t, serve(preflight), [][]string{{}})
+}
@@ -0,0 +1,37 @@
+package jsonrpc
+
+// jsonDepthExceeds reports whether the JSON document data nests objects and
+// arrays more than max levels deep. It doesn't validate data; it only counts
+// brackets that appear outside of strings.
+func jsonDepthExceeds(data []byte, max int) bool {
+	var (
+		depth    int
+		inString bool
+		escaped  bool
+	)
+	for _, c := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > max {
+				return true
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return false
+}
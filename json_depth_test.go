@@ -0,0 +1,26 @@
+package jsonrpc
+
+import "testing"
+
+func TestJSONDepthExceeds(t *testing.T) {
+	tests := []struct {
+		json string
+		max  int
+		want bool
+	}{
+		{`1`, 0, false},
+		{`{}`, 0, true},
+		{`{}`, 1, false},
+		{`{"a": [1, {"b": 2}]}`, 2, true},
+		{`{"a": [1, {"b": 2}]}`, 3, false},
+		{`[[], [], []]`, 2, false},
+		{`{"a": "[[[[[["}`, 1, false},
+		{`{"a": "\"[[[["}`, 1, false},
+		{`{"a": "\\", "b": [[]]}`, 2, true},
+	}
+	for _, tt := range tests {
+		if got := jsonDepthExceeds([]byte(tt.json), tt.max); got != tt.want {
+			t.Errorf("%s (max %d): got %v, want %v", tt.json, tt.max, got, tt.want)
+		}
+	}
+}
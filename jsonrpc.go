@@ -1,13 +1,22 @@
 package jsonrpc
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"path"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Handler is an http.Handler that dispatches requests to RPC handlers.
@@ -16,15 +25,232 @@ type Handler struct {
 	// response; useful for local debugging.
 	DumpErrors bool
 
+	// DumpErrorNames lists the names of the errors whose underlying errors
+	// are displayed when DumpErrors is enabled. Defaults to
+	// []string{"internal_error"}, so that details wrapped by client-facing
+	// errors, such as invalid_params, are never leaked.
+	DumpErrorNames []string
+
+	// AllowBatch indicates if batch requests are accepted. When false, any
+	// request whose body is a JSON array is rejected. Defaults to true.
+	AllowBatch bool
+
+	// RejectMalformedBatches indicates if a batch should fail as a whole at
+	// its first element that is not a well-formed request, with a method and
+	// a valid id, rather than that element failing alone. The rest of the
+	// batch is then not read, which bounds the work a malformed batch can
+	// cause.
+	RejectMalformedBatches bool
+
+	// Localizer, if set, translates the messages of errors that have a
+	// message catalog key (see RPCError.Key). lang is the client's preferred
+	// language, taken from the Accept-Language header, and may be empty.
+	Localizer func(lang, key string, args ...interface{}) string
+
+	// TimeEncoder, if set, replaces time.Time values in method results before
+	// they are rendered, e.g. to render times as epoch milliseconds rather
+	// than RFC 3339 strings. It applies to results that are a time.Time, and
+	// to times nested within M, map[string]interface{} and []interface{}
+	// values, but not to struct fields.
+	TimeEncoder func(t time.Time) interface{}
+
+	// ExperimentHeaders lists the HTTP headers from which experiments are
+	// parsed. Experiments are available to methods and middleware via
+	// ExperimentsFromContext.
+	ExperimentHeaders []string
+
+	// AllowDryRun indicates if clients may validate requests without side
+	// effects by sending the header "X-Dry-Run: true". Such requests are
+	// parsed and passed through all middleware as usual, but in place of
+	// each method, its params are validated if they implement Validator, and
+	// the result is null.
+	AllowDryRun bool
+
+	// SuggestMethods indicates if method_not_found errors should suggest the
+	// closest registered method name, under "data". This has a cost
+	// proportional to the number of registered methods.
+	SuggestMethods bool
+
+	// Meta holds server-wide metadata, such as the server version, rendered
+	// under "meta" in every response. Methods may add to or override it per
+	// request with SetResponseMeta.
+	Meta M
+
+	// ErrorStatusMode controls the HTTP status of single responses containing
+	// an error. Defaults to AlwaysOK.
+	ErrorStatusMode ErrorStatusMode
+
+	// ErrorStatuses maps error names to HTTP statuses when ErrorStatusMode is
+	// MapFromName, overriding the defaults.
+	ErrorStatuses map[string]int
+
+	// MaxParamsDepth is the maximum depth to which objects and arrays may be
+	// nested in params. Zero means no limit.
+	MaxParamsDepth int
+
+	// MaxFrameSize is the maximum size, in bytes, of the body of a frame read
+	// by ServeStdio. Larger frames fail with a parse_error, ending the
+	// stream. Defaults to 10 MiB.
+	MaxFrameSize int
+
+	// RejectUnexpectedParams indicates if calls to methods that take no
+	// params should fail with an invalid_params error if params other than
+	// null are sent, rather than ignoring them, to catch clients calling the
+	// wrong method.
+	RejectUnexpectedParams bool
+
+	// ServerTiming indicates if a Server-Timing header should be added to
+	// responses, with the time spent parsing the request, dispatching it to
+	// methods, and encoding the response.
+	ServerTiming bool
+
+	// DurationMeta indicates if each response should include the time spent
+	// invoking its method, in fractional milliseconds, as "duration_ms" in its
+	// metadata.
+	DurationMeta bool
+
+	// AllowFieldSelection indicates if clients may request a subset of the
+	// top-level fields of object results, either with a "fields" array in
+	// the request or with a comma-separated X-Fields header.
+	AllowFieldSelection bool
+
+	// IDGenerator, if set, generates an id for each notification sent by
+	// the server, such as those emitted by streaming methods, so that clients
+	// may acknowledge or deduplicate them.
+	IDGenerator IDGenerator
+
+	// ErrorMethod indicates if error responses should include the name of the
+	// method that was called, under "method"; useful when tracing errors in
+	// client logs.
+	ErrorMethod bool
+
+	// VersionMode controls how the optional "jsonrpc" version member of
+	// requests is validated. Defaults to IgnoreVersion.
+	VersionMode VersionMode
+
+	// MaxConcurrency is the maximum number of method invocations that may be
+	// in flight at once, across all requests. When the limit is reached,
+	// further invocations fail with a service_unavailable error. Zero means
+	// no limit. It must not be changed once the handler is serving requests.
+	MaxConcurrency int
+
+	// WaitForConcurrency indicates if invocations over MaxConcurrency should
+	// wait for a slot to be freed, until their request's context is done,
+	// rather than failing immediately.
+	WaitForConcurrency bool
+
+	// StreamBatches indicates if clients may ask for the responses to a batch
+	// to be streamed, by sending an "Accept: application/x-ndjson" header.
+	// Each response is then written as a line of newline-delimited JSON, and
+	// flushed, as soon as its method returns, rather than buffering the whole
+	// batch.
+	StreamBatches bool
+
+	// PanicClassifier, if set, is called with the value recovered from a
+	// panicking method, and may map it to an error to return to the client,
+	// such as invalid_params for a library that panics on bad input. If it
+	// returns nil, or is not set, a panic with an *RPCError results in that
+	// error, and any other panic in an internal_error wrapping the recovered
+	// value.
+	PanicClassifier func(recovered interface{}) *RPCError
+
+	// OnParseError, if set, is called when the body of r cannot be read or
+	// parsed as a request or batch, with the raw body read so far and the
+	// error returned to the client; useful for logging malformed requests.
+	// raw may be large, and should be truncated before logging.
+	OnParseError func(r *http.Request, raw []byte, err error)
+
+	// CORS, if set, adds Cross-Origin Resource Sharing headers to responses,
+	// and responds to preflight requests, so the handler may be called by
+	// browser clients on other origins.
+	CORS *CORSConfig
+
+	// LenientNumbers indicates if numeric params may be sent as strings, such
+	// as {"count": "5"}, for clients that cannot send JSON numbers. This is
+	// non-standard, and only applies to params that fail to unmarshal as
+	// sent.
+	LenientNumbers bool
+
+	// MethodNameNormalizer, if set, maps method names to the form they are
+	// matched in, both when methods are registered and when they are called;
+	// for example, strings.ToLower makes method names case-insensitive.
+	// Registering two methods whose names normalize to the same form fails,
+	// as if they had the same name. It must be set before any methods are
+	// registered.
+	MethodNameNormalizer func(name string) string
+
+	// EchoMalformedIDs indicates if the response to a single request that
+	// cannot be parsed should have the request's id, when it can be recovered,
+	// rather than null; for example, the response to {"id": 1, "method": 2}
+	// has id 1. This is non-standard, since JSON-RPC requires a null id when
+	// the request cannot be parsed.
+	EchoMalformedIDs bool
+
+	// CacheSize is the maximum number of results cached for methods
+	// registered with RegisterCached. Defaults to 1000. It must not be
+	// changed once the handler is serving requests.
+	CacheSize int
+
+	// UseNumber indicates if numbers in params should be decoded into
+	// interface{} values, such as those of a map[string]interface{}, as a
+	// json.Number rather than a float64, so that large integers and decimal
+	// amounts keep their exact representation. Params of type json.Number
+	// keep it regardless.
+	UseNumber bool
+
+	// ServerVersion is the version of the server, reported to clients by
+	// the method registered with RegisterCapabilities.
+	ServerVersion string
+
+	// RequestInterceptor, if set, is called with each request before it is
+	// dispatched, and may rewrite its method or params, such as to translate
+	// a deprecated method name. If it returns an error, the method is not
+	// invoked, and the error is the response to the request.
+	RequestInterceptor func(req *Request) error
+
+	// EnvelopeMarshaler, if set, encodes each response in place of the
+	// default JSON-RPC envelope, such as for clients that expect a legacy
+	// format like {"ok": true, "data": ...}. Batches are encoded as an array
+	// of its results. It applies to buffered responses sent by ServeHTTP and
+	// Dispatch, but not to streamed ones.
+	EnvelopeMarshaler func(resp ResponseView) ([]byte, error)
+
+	// AfterResponse, if set, is called by ServeHTTP once per HTTP request,
+	// after the response has been written, such as to flush spans or release
+	// resources. It is passed the requests, and their responses in the same
+	// order, including those of methods that failed. If no requests were
+	// read, because the body could not be parsed or the HTTP method was not
+	// POST, reqs is nil and resps holds the error response; for a CORS
+	// preflight request, both are nil. The responses must not be retained
+	// after it returns.
+	AfterResponse func(ctx context.Context, reqs []*Request, resps []ResponseView)
+
+	// Clock, if set, is used in place of the system clock by time-dependent
+	// features, so that they may be tested with a FakeClock.
+	Clock Clock
+
+	mu      sync.RWMutex // guards methods, registered, groups and encoders
 	methods map[string]method
 	root    *Group
+
+	encoders map[reflect.Type]func(v interface{}) ([]byte, error) // RegisterResultEncoder
+
+	registered int // number of methods ever registered
+	groups     int // number of subgroups created
+
+	semOnce sync.Once
+	sem     chan struct{} // MaxConcurrency slots
+
+	cacheOnce sync.Once
+	cache     *lruCache // results of methods registered with RegisterCached
 }
 
 // New returns a new initialized handler.
 func New() *Handler {
 	h := &Handler{
-		methods: make(map[string]method),
-		root:    &Group{},
+		AllowBatch: true,
+		methods:    make(map[string]method),
+		root:       &Group{},
 	}
 	h.root.server = h
 	return h
@@ -33,9 +259,12 @@ func New() *Handler {
 // Group represents a set of RPC methods that share the same middleware. Groups
 // may be nested, and will inherit their parent's middleware as well.
 type Group struct {
-	server     *Handler
-	parent     *Group
-	middleware []Middleware
+	server        *Handler
+	parent        *Group
+	id            int // creation order of subgroups, starting at 1
+	middleware    []groupMiddleware
+	errorHandlers []ErrorHandler
+	errorMapper   func(error) error
 }
 
 // Next is the function passed into middleware to continue execution of the
@@ -44,6 +273,11 @@ type Next func(ctx context.Context, params interface{}) (interface{}, error)
 
 // Middleware is a function that wraps an RPC method to add new behavior.
 //
+// Middleware receives the result and error returned by next and may inspect
+// or replace either of them. The result is the typed value returned by the
+// method (it has not yet been marshaled to JSON). Any error returned by
+// middleware is handled exactly like an error returned by the method itself.
+//
 // For example, you might create a logging middleware that looks like:
 //
 //  func LoggingMiddleware(logger *logger.Logger) Middleware {
@@ -60,6 +294,11 @@ type Next func(ctx context.Context, params interface{}) (interface{}, error)
 //  }
 type Middleware func(Next) Next
 
+// ErrorHandler is a function that is called when an RPC method returns an
+// error. It may return the error unchanged, or replace it with another (for
+// example, mapping sql.ErrNoRows to NotFound).
+type ErrorHandler func(ctx context.Context, err error) error
+
 // Methods represents a map of RPC methods.
 type Methods map[string]MethodFunc
 
@@ -67,9 +306,13 @@ type Methods map[string]MethodFunc
 // subgroup may have its own middleware, but will also inherit its parent's
 // middleware.
 func (g *Group) Group() *Group {
+	g.server.mu.Lock()
+	defer g.server.mu.Unlock()
+	g.server.groups++
 	return &Group{
 		parent: g,
 		server: g.server,
+		id:     g.server.groups,
 	}
 }
 
@@ -78,17 +321,162 @@ func (g *Group) Group() *Group {
 // middleware.
 func (h *Handler) Group() *Group { return h.root.Group() }
 
-// Use registers middleware to be used for the methods in this group.
+// Use registers middleware to be used for the methods in this group. It panics
+// if methods have already been registered with the group or its subgroups.
 func (g *Group) Use(middleware ...Middleware) {
-	if len(g.server.methods) != 0 {
+	if g.hasMethods() {
 		panic("jsonrpc: middleware must be registered before methods")
 	}
-	g.middleware = append(g.middleware, middleware...)
+	for _, mw := range middleware {
+		g.middleware = append(g.middleware, groupMiddleware{mw: mw})
+	}
 }
 
 // Use registers middleware to be used for the methods in this group.
 func (h *Handler) Use(middleware ...Middleware) { h.root.Use(middleware...) }
 
+// UseFor registers middleware to be used for the methods in this group whose
+// names match pattern, such as "admin.*", with the syntax of path.Match. It is
+// otherwise like Use, and the middleware is applied in the same order as that
+// registered with Use. Methods are matched by the name they are registered
+// under. UseFor panics if pattern is malformed.
+func (g *Group) UseFor(pattern string, middleware ...Middleware) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic(fmt.Sprintf("jsonrpc: invalid pattern %q: %v", pattern, err))
+	}
+	if g.hasMethods() {
+		panic("jsonrpc: middleware must be registered before methods")
+	}
+	for _, mw := range middleware {
+		g.middleware = append(g.middleware, groupMiddleware{mw: mw, pattern: &pattern})
+	}
+}
+
+// UseFor registers middleware to be used for the methods whose names match
+// pattern.
+func (h *Handler) UseFor(pattern string, middleware ...Middleware) { h.root.UseFor(pattern, middleware...) }
+
+// groupMiddleware is middleware registered with a group.
+type groupMiddleware struct {
+	mw      Middleware
+	pattern *string // set by UseFor
+}
+
+// appliesTo reports whether the middleware wraps the method with the given
+// name.
+func (gm groupMiddleware) appliesTo(name string) bool {
+	if gm.pattern == nil {
+		return true
+	}
+	ok, _ := path.Match(*gm.pattern, name)
+	return ok
+}
+
+// UseErrorHandler registers error handlers to be used for the methods in this
+// group. Error handlers only run when a method returns an error, and run
+// outside of all middleware, so they also see errors returned by middleware.
+// Error handlers of a subgroup run before those of its parent.
+func (g *Group) UseErrorHandler(handlers ...ErrorHandler) {
+	if g.hasMethods() {
+		panic("jsonrpc: error handlers must be registered before methods")
+	}
+	g.errorHandlers = append(g.errorHandlers, handlers...)
+}
+
+// UseErrorHandler registers error handlers to be used for the methods in this
+// group. Error handlers only run when a method returns an error, and run
+// outside of all middleware, so they also see errors returned by middleware.
+// Error handlers of a subgroup run before those of its parent.
+func (h *Handler) UseErrorHandler(handlers ...ErrorHandler) { h.root.UseErrorHandler(handlers...) }
+
+// Override replaces the implementation of a registered method. The new
+// implementation uses the middleware, error handlers and metadata of the
+// group the method was originally registered with. It is safe to call while
+// the handler is serving requests. Override panics if the method is not
+// registered or fn has an invalid signature.
+func (h *Handler) Override(name string, fn MethodFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := h.methodKey(name)
+	old, ok := h.methods[key]
+	if !ok {
+		panic("jsonrpc: method not registered: " + name)
+	}
+	// Mounted methods are resolved with the name they were registered with,
+	// which the middleware of their group is matched against.
+	registered := old.Name
+	if old.mount != nil {
+		registered = strings.TrimPrefix(old.Name, *old.mount)
+	}
+	m, err := old.group.resolveMethod(registered, fn, old.info.Meta, old.cache)
+	if err != nil {
+		panic(err.Error())
+	}
+	m.Name = old.Name
+	m.info.Name = old.Name
+	m.seq = old.seq
+	m.mount = old.mount
+	h.methods[key] = m
+}
+
+// Unregister removes a registered method, if present. It is safe to call while
+// the handler is serving requests.
+func (h *Handler) Unregister(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.methods, h.methodKey(name))
+}
+
+// lookup returns the registered method with the given name.
+func (h *Handler) lookup(name string) (method, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	m, ok := h.methods[h.methodKey(name)]
+	return m, ok
+}
+
+// methodKey returns the key that the method with the given name is stored
+// under, applying MethodNameNormalizer.
+func (h *Handler) methodKey(name string) string {
+	if h.MethodNameNormalizer != nil {
+		return h.MethodNameNormalizer(name)
+	}
+	return name
+}
+
+// hasMethods reports whether any methods have been registered with g or any
+// of its subgroups.
+func (g *Group) hasMethods() bool {
+	h := g.server
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, m := range h.methods {
+		for mg := m.group; mg != nil; mg = mg.parent {
+			if mg == g {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MapErrors sets a function that transforms errors returned by the methods in
+// this group, e.g. to convert domain errors into RPCErrors. The mapper is
+// applied directly to the method's error, before any middleware sees it. The
+// mappers of a subgroup are applied before those of its parent.
+func (g *Group) MapErrors(fn func(err error) error) {
+	if g.hasMethods() {
+		panic("jsonrpc: error mappers must be set before methods are registered")
+	}
+	g.errorMapper = fn
+}
+
+// MapErrors sets a function that transforms errors returned by the methods in
+// this group, e.g. to convert domain errors into RPCErrors. The mapper is
+// applied directly to the method's error, before any middleware sees it. The
+// mappers of a subgroup are applied before those of its parent.
+func (h *Handler) MapErrors(fn func(err error) error) { h.root.MapErrors(fn) }
+
 // Register registers the set of methods owned by this group.
 //
 // For example:
@@ -97,12 +485,108 @@ func (h *Handler) Use(middleware ...Middleware) { h.root.Use(middleware...) }
 //      "GetUser": getUserMethod,
 //  })
 func (g *Group) Register(methods Methods) {
-	for name, m := range methods {
-		if _, ok := g.server.methods[name]; ok {
-			panic("jsonrpc: method already registered: " + name)
+	g.RegisterWithMeta(methods, nil)
+}
+
+// RegisterWithMeta registers the set of methods owned by this group, along
+// with metadata for some or all of them. Metadata is available to middleware
+// via MethodInfoFromContext.
+//
+// For example:
+//  g.RegisterWithMeta(Methods{
+//      "Login":   loginMethod,
+//      "GetUser": getUserMethod,
+//  }, map[string]Meta{
+//      "Login": {Public: true, RateLimit: 10},
+//  })
+func (g *Group) RegisterWithMeta(methods Methods, meta map[string]Meta) {
+	if err := g.register(methods, meta, nil); err != nil {
+		panic(err.Error())
+	}
+}
+
+// TryRegister registers the set of methods owned by this group, like
+// Register, but returns an error rather than panicking if a method is already
+// registered or has an invalid signature. If an error is returned, none of
+// the methods are registered.
+func (g *Group) TryRegister(methods Methods) error {
+	return g.register(methods, nil, nil)
+}
+
+// RegisterAll registers the methods of all of maps with this group, as if
+// they were a single Methods map, which is useful when methods are defined
+// across several files. If a method is defined in more than one of maps,
+// RegisterAll panics with an error naming both definitions, before any of the
+// methods are registered.
+func (g *Group) RegisterAll(maps ...Methods) {
+	merged, err := mergeMethods(maps)
+	if err != nil {
+		panic(err.Error())
+	}
+	g.Register(merged)
+}
+
+// mergeMethods merges maps into a single Methods map, returning an error if a
+// method is defined more than once.
+func mergeMethods(maps []Methods) (Methods, error) {
+	merged := make(Methods)
+	source := make(map[string]int)
+	for i, methods := range maps {
+		for name, fn := range methods {
+			if j, ok := source[name]; ok {
+				return nil, fmt.Errorf("jsonrpc: method %s defined twice: by %s (maps[%d]) and %s (maps[%d])",
+					name, funcName(merged[name]), j, funcName(fn), i)
+			}
+			merged[name] = fn
+			source[name] = i
+		}
+	}
+	return merged, nil
+}
+
+func (g *Group) register(methods Methods, meta map[string]Meta, cache *cachePolicy) error {
+	for name := range meta {
+		if _, ok := methods[name]; !ok {
+			return errors.New("jsonrpc: meta provided for unknown method: " + name)
+		}
+	}
+	g.server.mu.Lock()
+	defer g.server.mu.Unlock()
+	names := make([]string, 0, len(methods))
+	for name := range methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	resolved := make(map[string]method, len(methods))
+	for _, name := range names {
+		key := g.server.methodKey(name)
+		if old, ok := g.server.methods[key]; ok {
+			if old.Name != name {
+				return fmt.Errorf("jsonrpc: method %s already registered as %s", name, old.Name)
+			}
+			return errors.New("jsonrpc: method already registered: " + name)
+		}
+		if other, ok := resolved[key]; ok {
+			return fmt.Errorf("jsonrpc: methods %s and %s have the same normalized name", other.Name, name)
+		}
+		m := meta[name]
+		if cache != nil {
+			m.Cacheable = true
 		}
-		g.server.methods[name] = g.resolveMethod(name, m)
+		rm, err := g.resolveMethod(name, methods[name], m, cache)
+		if err != nil {
+			return err
+		}
+		resolved[key] = rm
+	}
+	for _, name := range names {
+		key := g.server.methodKey(name)
+		m := resolved[key]
+		g.server.registered++
+		m.seq = g.server.registered
+		g.server.methods[key] = m
 	}
+	return nil
 }
 
 // Register registers the set of methods owned by this group.
@@ -114,34 +598,198 @@ func (g *Group) Register(methods Methods) {
 //  })
 func (h *Handler) Register(methods Methods) { h.root.Register(methods) }
 
+// RegisterWithMeta registers the set of methods owned by this group, along
+// with metadata for some or all of them. Metadata is available to middleware
+// via MethodInfoFromContext.
+//
+// For example:
+//  g.RegisterWithMeta(Methods{
+//      "Login":   loginMethod,
+//      "GetUser": getUserMethod,
+//  }, map[string]Meta{
+//      "Login": {Public: true, RateLimit: 10},
+//  })
+func (h *Handler) RegisterWithMeta(methods Methods, meta map[string]Meta) {
+	h.root.RegisterWithMeta(methods, meta)
+}
+
+// RegisterAll registers the methods of all of maps with this group, as if
+// they were a single Methods map, which is useful when methods are defined
+// across several files. If a method is defined in more than one of maps,
+// RegisterAll panics with an error naming both definitions, before any of the
+// methods are registered.
+func (h *Handler) RegisterAll(maps ...Methods) { h.root.RegisterAll(maps...) }
+
+// TryRegister registers the set of methods owned by this group, like
+// Register, but returns an error rather than panicking if a method is already
+// registered or has an invalid signature. If an error is returned, none of
+// the methods are registered.
+func (h *Handler) TryRegister(methods Methods) error { return h.root.TryRegister(methods) }
+
 type request struct {
-	Method string          `json:"method"` // Method Name
-	Params json.RawMessage `json:"params"` // Method Parameters
-	ID     interface{}     `json:"id"`     // Request ID, useful for batches
+	Version json.RawMessage `json:"jsonrpc"` // Optional protocol version
+	Method  string          `json:"method"`  // Method Name
+	Params  json.RawMessage `json:"params"`  // Method Parameters
+	ID      interface{}     `json:"id"`      // Request ID, useful for batches
+	Fields  json.RawMessage `json:"fields"`  // Optional result field selection
+
+	err error // fails the request; set by decodeBatch or RequestInterceptor
 }
 
 type response struct {
-	Result interface{} `json:"result,omitempty"`
-	Error  *RPCError   `json:"error,omitempty"`
-	ID     interface{} `json:"id"`
+	Result   interface{} `json:"result,omitempty"`
+	Error    *RPCError   `json:"error,omitempty"`
+	Warnings []string    `json:"warnings,omitempty"`
+	Meta     M           `json:"meta,omitempty"`
+	ID       interface{} `json:"id"`
+
+	partial  bool // result and error from a PartialResult
+	accepted bool // result from Accepted
+	noReply  bool // to a notification in a batch: not sent
+}
+
+// answered returns the responses to be sent to the client, omitting those to
+// notifications. responses is returned as is if there are none.
+func answered(responses []*response) []*response {
+	for i, resp := range responses {
+		if !resp.noReply {
+			continue
+		}
+		result := append([]*response(nil), responses[:i]...)
+		for _, resp := range responses[i+1:] {
+			if !resp.noReply {
+				result = append(result, resp)
+			}
+		}
+		return result
+	}
+	return responses
 }
 
 // M is a shorthand for map[string]interface{}. Responses from the server may be
 // of this type.
 type M map[string]interface{}
 
+// contextKey is the type of the keys of the values a Handler adds to contexts.
+// Being unexported, it cannot collide with keys defined by other packages.
+// Each key has an exported accessor, listed in the package documentation.
 type contextKey int
 
 const (
-	contextKeyMethod contextKey = iota
-	contextKeyRequest
+	contextKeyRequest        contextKey = iota // *http.Request: RequestFromContext
+	contextKeyState                            // *requestState: MethodFromContext, etc.
+	contextKeyExperiments                      // Experiments: ExperimentsFromContext
+	contextKeyResponseHeader                   // http.Header: SetResponseHeader
+	contextKeyTraceID                          // string: TraceIDFromContext
 )
 
+// requestState holds the values made available to methods and middleware for
+// a single RPC request. It's stored under a single context key to avoid
+// allocating a new context for each value.
+type requestState struct {
+	id     interface{}
+	method string
+	info   *MethodInfo
+	params json.RawMessage
+	emit   Emit
+	notify notifier // sends notifications when streaming: Progress
+
+	inBatch    bool
+	batchIndex int
+	batchTotal int
+	noReply    bool     // a notification in a batch: ids are not required
+	dryRun     bool     // Handler.AllowDryRun and X-Dry-Run: skip the method
+	fields     []string // result fields selected by the client
+	renderLate bool     // results are rendered by SingleflightMiddleware
+
+	mu       sync.Mutex
+	warnings []string
+	meta     M
+	memo     map[interface{}]*call // values memoized by Once
+
+	clock Clock // Handler.Clock: ClockFromContext
+}
+
+func (s *requestState) addWarning(msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.warnings = append(s.warnings, msg)
+}
+
+func (s *requestState) listWarnings() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.warnings
+}
+
+func (s *requestState) setMeta(key string, val interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.meta == nil {
+		s.meta = make(M)
+	}
+	s.meta[key] = val
+}
+
+// responseMeta merges the request's metadata over defaults.
+func (s *requestState) responseMeta(defaults M) M {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.meta) == 0 {
+		return defaults
+	}
+	if len(defaults) == 0 {
+		return s.meta
+	}
+	result := make(M, len(defaults)+len(s.meta))
+	for k, v := range defaults {
+		result[k] = v
+	}
+	for k, v := range s.meta {
+		result[k] = v
+	}
+	return result
+}
+
+func stateFromContext(ctx context.Context) *requestState {
+	s, _ := ctx.Value(contextKeyState).(*requestState)
+	return s
+}
+
 // MethodFromContext extracts the RPC method name from the given
 // context.Context.
 func MethodFromContext(ctx context.Context) string {
-	s, _ := ctx.Value(contextKeyMethod).(string)
-	return s
+	if s := stateFromContext(ctx); s != nil {
+		return s.method
+	}
+	return ""
+}
+
+// RequestIDFromContext extracts the id of the current RPC request from the
+// given context.Context. It is a float64 or string, or nil if absent.
+func RequestIDFromContext(ctx context.Context) interface{} {
+	if s := stateFromContext(ctx); s != nil {
+		return s.id
+	}
+	return nil
+}
+
+// MethodInfoFromContext extracts the resolved RPC method from the given
+// context.Context. It returns nil if the method could not be found.
+func MethodInfoFromContext(ctx context.Context) *MethodInfo {
+	if s := stateFromContext(ctx); s != nil {
+		return s.info
+	}
+	return nil
+}
+
+// RawParamsFromContext extracts the raw JSON params of the current RPC request
+// from the given context.Context. It returns nil if no params were provided.
+func RawParamsFromContext(ctx context.Context) json.RawMessage {
+	if s := stateFromContext(ctx); s != nil {
+		return s.params
+	}
+	return nil
 }
 
 // RequestFromContext extracts the underlying http.Request from the given
@@ -151,71 +799,504 @@ func RequestFromContext(ctx context.Context) *http.Request {
 	return r
 }
 
+// SetResponseMeta sets a metadata value for the current request, rendered
+// under "meta" in its response, alongside any Handler.Meta. It is a no-op if
+// ctx did not originate from a Handler.
+func SetResponseMeta(ctx context.Context, key string, val interface{}) {
+	if s := stateFromContext(ctx); s != nil {
+		s.setMeta(key, val)
+	}
+}
+
+// SetResponseHeader sets a header on the HTTP response for the current
+// request, replacing any existing values. It is a no-op if ctx did not
+// originate from a Handler. Headers set by streaming methods are ignored,
+// since the response headers have already been sent.
+func SetResponseHeader(ctx context.Context, key, value string) {
+	if header, ok := ctx.Value(contextKeyResponseHeader).(http.Header); ok {
+		header.Set(key, value)
+	}
+}
+
+// BatchInfoFromContext extracts the position of the current request within
+// its batch, and the total number of requests in the batch, from the given
+// context.Context. If the request is not part of a batch, ok is false.
+func BatchInfoFromContext(ctx context.Context) (index, total int, ok bool) {
+	s := stateFromContext(ctx)
+	if s == nil || !s.inBatch {
+		return 0, 0, false
+	}
+	return s.batchIndex, s.batchTotal, true
+}
+
+// AddWarning attaches a non-fatal warning to the response for the current
+// request. Warnings are rendered to the client under "warnings", alongside the
+// result or error. It is a no-op if ctx did not originate from a Handler.
+func AddWarning(ctx context.Context, msg string, args ...interface{}) {
+	s := stateFromContext(ctx)
+	if s == nil {
+		return
+	}
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+	s.addWarning(msg)
+}
+
 // ServeHTTP implements the http.Handler interface.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	clock := h.clock()
+	start := clock.Now()
+	if h.CORS != nil && h.CORS.handle(w, r) {
+		h.afterResponse(r.Context(), nil, nil)
+		return
+	}
+	trace := traceID(r)
+	w.Header().Set(traceIDHeader, trace)
+	if r.Method != http.MethodPost {
+		allow := "POST"
+		if h.CORS != nil {
+			allow += ", OPTIONS"
+		}
+		w.Header().Set("Allow", allow)
+		resp := &response{
+			Error: InvalidRequest("HTTP method %s not allowed", r.Method),
+		}
+		h.sendResponse(w, http.StatusMethodNotAllowed, resp)
+		h.afterResponse(r.Context(), nil, []*response{resp})
+		return
+	}
 	ctx := context.WithValue(r.Context(), contextKeyRequest, r)
+	ctx = context.WithValue(ctx, contextKeyResponseHeader, w.Header())
+	ctx = context.WithValue(ctx, contextKeyTraceID, trace)
+	if e := parseExperiments(r, h.ExperimentHeaders); e != nil {
+		ctx = context.WithValue(ctx, contextKeyExperiments, e)
+	}
 
-	requests, err := parseRequests(r)
+	requests, batch, errID, err := h.parseRequests(r)
 	if err != nil {
-		sendJSON(w, 400, response{
+		resp := &response{
 			Error: translateError(err),
-		})
+			ID:    errID,
+		}
+		h.sendResponse(w, 400, resp)
+		h.afterResponse(ctx, nil, []*response{resp})
 		return
 	}
+	h.intercept(requests)
+	parsed := clock.Now()
 
-	responses := make([]*response, 0, len(requests))
-	for _, req := range requests {
-		result, err := h.invokeMethod(ctx, req)
-		responses = append(responses, &response{
-			ID:     req.ID,
-			Result: result,
-			Error:  translateError(err),
-		})
+	if m, ok := h.lookup(requests[0].Method); ok && m.stream && !batch && requests[0].err == nil {
+		resp := h.serveStream(ctx, w, requests[0])
+		h.afterResponse(ctx, requests, []*response{resp})
+		return
+	}
+
+	if batch && h.StreamBatches && acceptsNDJSON(r) {
+		responses := h.serveBatchStream(ctx, w, r, requests)
+		h.afterResponse(ctx, requests, responses)
+		return
+	}
+
+	responses := h.dispatch(ctx, r, requests, batch, nil)
+	defer releaseResponses(responses)
+	defer h.afterResponse(ctx, requests, responses)
+	if !batch && isRecvChan(responses[0].Result) {
+		h.serveChunked(ctx, w, requests[0], responses[0])
+		return
+	}
+	if raw, ok := asRawResponse(responses[0].Result); ok && !batch {
+		raw.send(w)
+		return
+	}
+	dispatched := clock.Now()
+
+	var (
+		status  = 200
+		payload interface{}
+	)
+	if !batch {
+		if err := responses[0].Error; err != nil && !responses[0].partial {
+			status = h.errorStatus(err)
+			if err.location != "" {
+				w.Header().Set("Location", err.location)
+			}
+			if err.retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(err.retryAfter)))
+			}
+		}
+		if responses[0].accepted && responses[0].Error == nil {
+			status = http.StatusAccepted
+		}
+		payload = responses[0]
+	} else {
+		answered := answered(responses)
+		if len(answered) == 0 {
+			// A batch of notifications has no response at all.
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		payload = answered
 	}
 
-	if h.DumpErrors {
-		for _, r := range responses {
-			if r.Error != nil {
-				r.Error.dumpErrors = true
+	e, err := h.encodePayload(payload)
+	if err != nil {
+		// Replace the responses that cannot be encoded with internal errors.
+		for i, resp := range responses {
+			if resp.noReply {
+				continue
 			}
+			if _, err := h.marshalResponse(resp); err != nil {
+				responses[i] = h.encodeErrorResponse(r, requests[i], resp, err)
+			}
+		}
+		if !batch {
+			status = http.StatusInternalServerError
+			payload = responses[0]
+		} else {
+			payload = answered(responses)
+		}
+		if e, err = h.encodePayload(payload); err != nil {
+			sendJSON(w, http.StatusInternalServerError, response{Error: InternalError(err)})
+			return
+		}
+	}
+	defer e.release()
+	if h.ServerTiming {
+		w.Header().Set("Server-Timing", fmt.Sprintf(
+			"parse;dur=%.3f, dispatch;dur=%.3f, encode;dur=%.3f",
+			millis(parsed.Sub(start)),
+			millis(dispatched.Sub(parsed)),
+			millis(clock.Now().Sub(dispatched)),
+		))
+	}
+	e.send(w, status)
+}
+
+// Dispatch handles a single request or a batch of requests encoded in raw,
+// returning the encoded response, for use with transports other than HTTP,
+// such as message queue consumers. Malformed requests, and results that
+// cannot be encoded, result in error responses, as with ServeHTTP; an error
+// is only returned if the response cannot be encoded even then. A batch of only
+// notifications has no response, so nil is returned.
+//
+// Since there is no HTTP request, RequestFromContext returns nil for methods
+// invoked this way. Streaming methods cannot be invoked, and results that are
+// channels are collected into an array.
+func (h *Handler) Dispatch(ctx context.Context, raw []byte) ([]byte, error) {
+	requests, batch, err := h.parseBody(raw)
+	if err != nil {
+		return h.marshalPayload(&response{Error: translateError(err), ID: h.errorID(raw)})
+	}
+	h.intercept(requests)
+	responses := h.dispatch(ctx, nil, requests, batch, nil)
+	if !batch && isRecvChan(responses[0].Result) {
+		responses[0].Result = h.collectChan(ctx, responses[0].Result)
+	}
+	replaceUnencodable(responses)
+	if batch {
+		answered := answered(responses)
+		if len(answered) == 0 {
+			return nil, nil
 		}
+		return h.marshalPayload(answered)
 	}
+	return h.marshalPayload(responses[0])
+}
 
-	if len(requests) == 1 {
-		sendJSON(w, 200, responses[0])
-	} else {
-		sendJSON(w, 200, responses)
+// dispatch invokes each of requests, returning their responses in the same
+// order as requests, which clients may rely on. r is the HTTP request the
+// requests were received in, and may be nil for other transports. If notify is
+// non-nil, it is made available to streaming methods, and to Progress.
+func (h *Handler) dispatch(ctx context.Context, r *http.Request, requests []*request, batch bool, notify notifier) []*response {
+	responses := newResponses(len(requests))
+	h.dispatchEach(ctx, r, requests, batch, notify, func(_ *request, resp *response) {
+		responses = append(responses, resp)
+	})
+	return responses
+}
+
+// dispatchEach is like dispatch, but passes each request and its response to
+// fn as soon as the response is ready, in the same order as requests.
+func (h *Handler) dispatchEach(ctx context.Context, r *http.Request, requests []*request, batch bool, notify notifier, fn func(*request, *response)) {
+	for i, req := range requests {
+		// Requests in a batch without an id are notifications, which are
+		// invoked but not answered.
+		noReply := batch && req.ID == nil && req.err == nil
+
+		// Stop processing the batch if the client has gone away.
+		if err := ctx.Err(); err != nil {
+			fn(req, &response{
+				ID:      req.ID,
+				Error:   RequestCancelled(err),
+				noReply: noReply,
+			})
+			continue
+		}
+		state := &requestState{
+			clock:      h.Clock,
+			emit:       notify.bind(req.Method),
+			notify:     notify,
+			inBatch:    batch,
+			batchIndex: i,
+			batchTotal: len(requests),
+			noReply:    noReply,
+		}
+		start := h.clock().Now()
+		result, err := h.invokeMethod(context.WithValue(ctx, contextKeyState, state), req)
+		if h.DurationMeta {
+			state.setMeta("duration_ms", millis(h.clock().Now().Sub(start)))
+		}
+		result, accepted := splitAccepted(result)
+		result, partialErr := splitPartial(result)
+		if state.fields != nil && err == nil && result != nil {
+			result = filterFields(result, state.fields)
+		}
+		resp := newResponse()
+		resp.ID = req.ID
+		resp.Result = result
+		resp.Error = translateError(err)
+		resp.Warnings = state.listWarnings()
+		resp.Meta = state.responseMeta(h.Meta)
+		resp.accepted = accepted && err == nil
+		resp.noReply = noReply
+		if partialErr != nil {
+			resp.Error = partialErr
+			resp.partial = true
+		}
+		if resp.Error != nil {
+			resp.Error = h.prepareError(r, req.Method, resp.Error)
+		}
+		fn(req, resp)
+	}
+}
+
+// millis returns d in fractional milliseconds.
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// prepareError returns err, returned by method, readied to be rendered to the
+// client of r. err is copied rather than modified, since methods may return
+// the same *RPCError from concurrent requests.
+func (h *Handler) prepareError(r *http.Request, method string, err *RPCError) *RPCError {
+	e := *err
+	if h.DumpErrors && h.dumpsError(e.Name) {
+		e.dumpErrors = true
+	}
+	if h.ErrorMethod {
+		e.method = method
+	}
+	if h.Localizer != nil && e.key != "" {
+		var lang string
+		if r != nil {
+			lang = preferredLanguage(r.Header.Get("Accept-Language"))
+		}
+		e.Message = h.Localizer(lang, e.key, e.keyArgs...)
+	}
+	return &e
+}
+
+// dumpsError reports whether the underlying errors of errors with the given
+// name should be displayed when DumpErrors is enabled.
+func (h *Handler) dumpsError(name string) bool {
+	if h.DumpErrorNames == nil {
+		return name == "internal_error"
+	}
+	return contains(h.DumpErrorNames, name)
+}
+
+type notification struct {
+	Method string      `json:"method"`
+	Params interface{} `json:"params,omitempty"`
+	ID     interface{} `json:"id,omitempty"` // from Handler.IDGenerator
+}
+
+// newNotification returns a notification for method with params, with an id
+// from h.IDGenerator, if set.
+func (h *Handler) newNotification(method string, params interface{}) notification {
+	n := notification{Method: method, Params: params}
+	if h.IDGenerator != nil {
+		n.ID = h.IDGenerator.NextID()
+	}
+	return n
+}
+
+// notifier sends a notification to the client.
+type notifier func(method string, params interface{}) error
+
+// ErrStreamClosed is returned by Emit and Progress once the response to the
+// request has been written, such as when called by a goroutine that outlives
+// its method.
+var ErrStreamClosed = errors.New("jsonrpc: stream closed")
+
+// closable returns a notifier that sends with n until stop is called. stop
+// waits for any notification being sent, so that none is written after the
+// response; later ones fail with ErrStreamClosed.
+func (n notifier) closable() (send notifier, stop func()) {
+	var (
+		mu     sync.Mutex
+		closed bool
+	)
+	send = func(method string, params interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return ErrStreamClosed
+		}
+		return n(method, params)
+	}
+	stop = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		closed = true
+	}
+	return send, stop
+}
+
+// bind returns an Emit that sends notifications for method, or nil if n is
+// nil.
+func (n notifier) bind(method string) Emit {
+	if n == nil {
+		return nil
+	}
+	return func(v interface{}) error {
+		return n(method, v)
+	}
+}
+
+// serveStream invokes a streaming method, writing each emitted notification
+// as newline-delimited JSON, followed by the final response, which it returns.
+func (h *Handler) serveStream(ctx context.Context, w http.ResponseWriter, req *request) *response {
+	w.Header().Set("content-type", "application/x-ndjson; charset=utf-8")
+	w.WriteHeader(200)
+
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	notify, closeNotify := notifier(func(method string, params interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := enc.Encode(h.newNotification(method, params)); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}).closable()
+	defer closeNotify()
+
+	state := &requestState{clock: h.Clock, emit: notify.bind(req.Method), notify: notify}
+	result, err := h.invokeMethod(context.WithValue(ctx, contextKeyState, state), req)
+	result, _ = splitAccepted(result)
+	result, partialErr := splitPartial(result)
+	resp := &response{
+		ID:       req.ID,
+		Result:   result,
+		Error:    translateError(err),
+		Warnings: state.listWarnings(),
+		Meta:     state.responseMeta(h.Meta),
+	}
+	if partialErr != nil {
+		resp.Error = partialErr
+	}
+	if resp.Error != nil {
+		resp.Error = h.prepareError(RequestFromContext(ctx), req.Method, resp.Error)
 	}
+
+	closeNotify()
+	mu.Lock()
+	defer mu.Unlock()
+	_ = enc.Encode(resp) // the client may have gone away
+	return resp
 }
 
 func (h *Handler) invokeMethod(ctx context.Context, req *request) (resp interface{}, err error) {
 	// Catch panics.
 	defer func() {
 		if r := recover(); r != nil {
-			rErr, ok := r.(error)
-			if !ok {
-				rErr = fmt.Errorf("%v", r)
-			}
 			resp = nil
-			err = InternalError(rErr)
+			if h.PanicClassifier != nil {
+				if rpcErr := h.PanicClassifier(r); rpcErr != nil {
+					err = rpcErr
+					return
+				}
+			}
+			switch r := r.(type) {
+			case *RPCError:
+				err = r
+			case error:
+				err = InternalError(r)
+			default:
+				err = InternalError(fmt.Errorf("%v", r))
+			}
 		}
 	}()
 
+	// Limit concurrent invocations.
+	release, err := h.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	// Inject method into context.
-	ctx = context.WithValue(ctx, contextKeyMethod, req.Method)
+	state := stateFromContext(ctx)
+	state.id = req.ID
+	state.method = req.Method
+	state.params = req.Params
+
+	// Fail requests rejected by the RequestInterceptor.
+	if req.err != nil {
+		return nil, req.err
+	}
+
+	// Find method.
+	method, ok := h.lookup(req.Method)
 
 	// Validate ID.
 	switch req.ID.(type) {
 	case float64, string:
+		if ok && method.info.Meta.Notification {
+			return nil, InvalidRequest("method %s is notification-only", req.Method)
+		}
+	case nil:
+		if !state.noReply && (!ok || !method.info.Meta.Notification) {
+			return nil, InvalidRequest("id must be number or string")
+		}
 	default:
 		return nil, InvalidRequest("id must be number or string")
 	}
+	if err := h.checkVersion(ctx, req); err != nil {
+		return nil, err
+	}
+	if h.AllowFieldSelection {
+		fields, err := selectedFields(RequestFromContext(ctx), req)
+		if err != nil {
+			return nil, err
+		}
+		state.fields = fields
+	}
 
-	// Find method.
-	method, ok := h.methods[req.Method]
 	if !ok {
-		return nil, MethodNotFound(req.Method)
+		err := MethodNotFound(req.Method)
+		if h.SuggestMethods {
+			if suggestion := h.suggestMethod(req.Method); suggestion != "" {
+				err.Data(M{"suggestion": suggestion})
+			}
+		}
+		return nil, err
 	}
+	// Expose the canonical name, not the client's spelling, so that
+	// middleware keyed by method name matches normalized calls. Mounted
+	// methods run the middleware of the handler they were registered with,
+	// which knows them by their unprefixed name.
+	state.method = method.Name
+	if method.mount != nil {
+		state.method = strings.TrimPrefix(method.Name, *method.mount)
+	}
+	state.info = method.info
 
 	// Instantiate params, if needed.
 	var params interface{}
@@ -224,67 +1305,289 @@ func (h *Handler) invokeMethod(ctx context.Context, req *request) (resp interfac
 		// if the method accepts `myParams`, this function will return a
 		// `*myParams` pointer to an empty `myParams` instance. It must be a
 		// pointer so that `json.Unmarshal` can write it.
+		if max := method.info.Meta.MaxParamsSize; max > 0 && len(req.Params) > max {
+			return nil, InvalidParams("params too large")
+		}
+		if h.MaxParamsDepth > 0 && jsonDepthExceeds(req.Params, h.MaxParamsDepth) {
+			return nil, InvalidParams("params too deeply nested")
+		}
 		params = method.newParams()
-		if err := json.Unmarshal(req.Params, params); err != nil {
-			return nil, ParseError(err, "cannot parse params")
+		err := h.unmarshalParams(req.Params, params)
+		if err != nil && h.LenientNumbers && isStringForNumber(err) {
+			if raw, ok := coerceNumbers(req.Params, method.paramsType); ok {
+				params = method.newParams()
+				err = h.unmarshalParams(raw, params)
+			}
+		}
+		if err != nil {
+			ok, posErr := unmarshalPositional(req.Params, params, err)
+			if !ok {
+				return nil, ParseError(err, "cannot parse params")
+			}
+			if posErr != nil {
+				if rpcErr, ok := posErr.(*RPCError); ok {
+					return nil, rpcErr
+				}
+				return nil, ParseError(posErr, "cannot parse params")
+			}
 		}
 
 		// Derefence the pointer from above before passing params along.
 		params = reflect.ValueOf(params).Elem().Interface()
+	} else if h.RejectUnexpectedParams && len(req.Params) > 0 && string(req.Params) != "null" {
+		return nil, InvalidParams("method %s takes no parameters", req.Method)
 	}
 
+	state.dryRun = h.AllowDryRun && isDryRun(RequestFromContext(ctx))
+
 	result, err := method.call(ctx, params)
 	if err != nil {
+		// Distinguish calls that failed because a context ended, whether
+		// the request's or one derived from it by middleware.
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			return nil, Timeout(err)
+		case errors.Is(err, context.Canceled):
+			return nil, RequestCancelled(err)
+		}
 		return nil, translateError(err)
 	}
-	return result, nil
+	if method.info.Meta.Notification {
+		return nil, nil // notifications have no result
+	}
+	if state.inBatch {
+		if _, raw := asRawResponse(result); raw || isRecvChan(result) {
+			return nil, InvalidRequest("method %s cannot be called in a batch", req.Method)
+		}
+	}
+	if raw, ok := result.(json.RawMessage); ok && !json.Valid(raw) {
+		return nil, InternalError(errors.New("method returned invalid raw JSON"))
+	}
+	if h.TimeEncoder != nil {
+		result = mapResult(result, func(v interface{}) interface{} {
+			return encodeTimes(v, h.TimeEncoder)
+		})
+	}
+	return h.encodeResult(result)
+}
+
+// isDryRun reports whether r asks for its RPC requests to be validated
+// without invoking any methods, via the X-Dry-Run header.
+func isDryRun(r *http.Request) bool {
+	if r == nil {
+		return false
+	}
+	dryRun, _ := strconv.ParseBool(r.Header.Get("X-Dry-Run"))
+	return dryRun
+}
+
+// inDryRun reports whether ctx belongs to a dry run, in which methods are not
+// invoked.
+func inDryRun(ctx context.Context) bool {
+	s := stateFromContext(ctx)
+	return s != nil && s.dryRun
+}
+
+// Validator is implemented by params that can check themselves. In a dry run,
+// Validate is called in place of the method, so that clients may check their
+// params against the rules of the method without side effects. Errors that
+// are not RPCErrors are returned as invalid_params errors.
+type Validator interface {
+	Validate() error
+}
+
+// wrapDryRun returns a Next that, in a dry run, validates params instead of
+// calling next.
+func wrapDryRun(next Next) Next {
+	return func(ctx context.Context, params interface{}) (interface{}, error) {
+		if !inDryRun(ctx) {
+			return next(ctx, params)
+		}
+		if v, ok := params.(Validator); ok {
+			if err := v.Validate(); err != nil {
+				if _, ok := err.(*RPCError); ok {
+					return nil, err
+				}
+				return nil, InvalidParams("%s", err)
+			}
+		}
+		return nil, nil
+	}
 }
 
-func parseRequests(r *http.Request) ([]*request, error) {
+// parseRequests reads and parses the body of r, reporting whether it was a
+// batch. If the body cannot be parsed, the id to respond with is returned
+// along with the error.
+func (h *Handler) parseRequests(r *http.Request) ([]*request, bool, interface{}, error) {
+	// Keep a copy of the body for OnParseError and EchoMalformedIDs, if
+	// needed.
+	var (
+		src io.Reader = r.Body
+		raw bytes.Buffer
+	)
+	if h.OnParseError != nil || h.EchoMalformedIDs {
+		src = io.TeeReader(r.Body, &raw)
+	}
+	fail := func(err error) ([]*request, bool, interface{}, error) {
+		if h.OnParseError != nil {
+			h.OnParseError(r, raw.Bytes(), err)
+		}
+		return nil, false, h.errorID(raw.Bytes()), err
+	}
+
+	// Decode single requests, and batches that are rejected as a whole if
+	// malformed, as they are read.
+	br := bufio.NewReader(src)
+	c, err := peekByte(br)
+	if err == nil && c == '{' {
+		req, err := decodeRequest(br)
+		if err != nil {
+			return fail(err)
+		}
+		return []*request{req}, false, nil, nil
+	}
+	if err == nil && c == '[' && h.AllowBatch && h.RejectMalformedBatches {
+		requests, err := decodeBatchStrict(br)
+		if err == nil {
+			err = checkRequests(requests)
+		}
+		if err != nil {
+			return fail(err)
+		}
+		return requests, true, nil, nil
+	}
+
 	// Read body.
-	body, err := ioutil.ReadAll(r.Body)
+	body, err := ioutil.ReadAll(br)
+	if err != nil {
+		return fail(InvalidRequest("could not read body").Wrap(err))
+	}
+	requests, batch, err := h.parseBody(body)
 	if err != nil {
-		return nil, InvalidRequest("could not read body").Wrap(err)
+		return fail(err)
 	}
+	return requests, batch, nil, nil
+}
+
+// parseBody parses a single request or a batch of requests, reporting whether
+// body was a batch.
+func (h *Handler) parseBody(body []byte) ([]*request, bool, error) {
 	body = bytes.TrimSpace(body)
 
 	// Parse body.
-	var result []*request
+	var (
+		result []*request
+		batch  bool
+	)
 	if len(body) > 0 && body[0] == '{' {
 		var req request
 		if err := json.Unmarshal(body, &req); err != nil {
-			return nil, ParseError(err, "cannot parse request")
+			return nil, false, ParseError(err, "cannot parse request")
 		}
 		result = append(result, &req)
 	} else {
-		if err := json.Unmarshal(body, &result); err != nil {
-			return nil, ParseError(err, "cannot parse request")
+		batch = true
+		if !h.AllowBatch && len(body) > 0 && body[0] == '[' {
+			return nil, false, InvalidRequest("batch not supported")
+		}
+		var err error
+		if h.RejectMalformedBatches && len(body) > 0 && body[0] == '[' {
+			result, err = decodeBatchStrict(bytes.NewReader(body))
+		} else {
+			result, err = decodeBatch(body)
 		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	if err := checkRequests(result); err != nil {
+		return nil, false, err
 	}
-	if len(result) == 0 {
-		return nil, InvalidRequest("empty batch")
+	return result, batch, nil
+}
+
+// checkRequests validates the requests parsed from a body.
+func checkRequests(requests []*request) error {
+	if len(requests) == 0 {
+		return InvalidRequest("empty batch")
 	}
 
-	// Assert ids are unique.
-	uniq := make(map[interface{}]struct{}, len(result))
-	for _, req := range result {
+	// Assert ids are unique. Requests without an id are notifications, which
+	// are not answered, so they are skipped.
+	uniq := make(map[interface{}]struct{}, len(requests))
+	for _, req := range requests {
+		if req.ID == nil || req.err != nil {
+			continue
+		}
 		if _, ok := uniq[req.ID]; ok {
-			return nil, InvalidRequest("ids must be unique")
+			return InvalidRequest("ids must be unique")
 		}
 		uniq[req.ID] = struct{}{}
 	}
+	return nil
+}
 
-	return result, nil
+// encodeErrorResponse returns the response to send in place of resp, whose
+// result or metadata could not be encoded.
+func (h *Handler) encodeErrorResponse(r *http.Request, req *request, resp *response, err error) *response {
+	rpcErr := h.prepareError(r, req.Method, InternalError(err))
+	return &response{
+		Error:    rpcErr,
+		Warnings: resp.Warnings,
+		ID:       resp.ID,
+	}
 }
 
-// sendJSON encodes v as JSON and writes it to the response body. Panics
-// if an encoding error occurs.
+// fallbackResponse is sent when a response cannot be encoded at all.
+const fallbackResponse = `{"error":{"name":"internal_error","message":"internal error","retryable":true},"id":null}`
+
+// sendJSON encodes v as JSON and writes it to the response body. If v cannot
+// be encoded, an internal_error is sent with status 500 instead.
 func sendJSON(w http.ResponseWriter, status int, v interface{}) {
+	e, err := encodeJSON(v)
+	if err != nil {
+		w.Header().Set("content-type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = io.WriteString(w, fallbackResponse+"\n")
+		return
+	}
+	defer e.release()
+	e.send(w, status)
+}
+
+// encoder is a reusable JSON encoder that writes to an in-memory buffer.
+type encoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+// encodeJSON encodes v as JSON using a pooled encoder, which must be released
+// once sent.
+func encodeJSON(v interface{}) (*encoder, error) {
+	e := encoderPool.Get().(*encoder)
+	e.buf.Reset()
+	if err := e.enc.Encode(v); err != nil {
+		encoderPool.Put(e)
+		return nil, err
+	}
+	return e, nil
+}
+
+// send writes the encoded JSON to w with the given status.
+func (e *encoder) send(w http.ResponseWriter, status int) {
 	w.Header().Set("content-type", "application/json; charset=utf-8")
 	w.WriteHeader(status)
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(v); err != nil {
-		panic(err)
-	}
+	_, _ = w.Write(e.buf.Bytes())
+}
+
+// release returns e to the pool.
+func (e *encoder) release() { encoderPool.Put(e) }
+
+var encoderPool = sync.Pool{
+	New: func() interface{} {
+		e := &encoder{}
+		e.enc = json.NewEncoder(&e.buf)
+		e.enc.SetIndent("", "  ")
+		return e
+	},
 }
@@ -2,16 +2,21 @@ package jsonrpc_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/deliveroo/assert-go"
 	"github.com/deliveroo/jsonrpc-go"
+	"github.com/deliveroo/jsonrpc-go/jsonrpctest"
 )
 
 func Example() {
@@ -201,7 +206,7 @@ func TestRPC(t *testing.T) {
 		{
 			name: "panic",
 			req:  `{"id": 1, "method": "Panic"}`,
-			resp: `{"id": 1, "error": {"name": "internal_error", "message": "internal error"}}`,
+			resp: `{"id": 1, "error": {"name": "internal_error", "message": "internal error", "retryable": true}}`,
 		},
 
 		// Invalid Requests:
@@ -215,6 +220,7 @@ func TestRPC(t *testing.T) {
 				},
 				"id": null
 			}`,
+			status: 400,
 		},
 		{
 			name: "dupe id",
@@ -231,6 +237,15 @@ func TestRPC(t *testing.T) {
 			}`,
 			status: 400,
 		},
+		{
+			name: "multiple missing ids",
+			req: `[
+				{"method": "Now"},
+				{"method": "Now", "id": null}
+			]`,
+			resp:   ``,
+			status: 204,
+		},
 		{
 			name: "invalid method",
 			req:  `{"id": 1, "method": "Invalid"}`,
@@ -264,6 +279,32 @@ func TestRPC(t *testing.T) {
 				},
 				"id": 1
 			}`,
+			status: 400,
+		},
+		{
+			name: "error in batch",
+			req: `[
+				{"id": 2, "method": "Missing"},
+				{"id": 1, "method": "Now"}
+			]`,
+			resp: `[
+				{
+					"error": {
+						"name": "method_not_found",
+						"message": "method not found: Missing"
+					},
+					"id": 2
+				},
+				{"id": 1, "result": "2000-01-01T01:00:00Z"}
+			]`,
+		},
+		{
+			name: "notification in batch",
+			req: `[
+				{"method": "Now"},
+				{"id": 1, "method": "Now"}
+			]`,
+			resp: `[{"id": 1, "result": "2000-01-01T01:00:00Z"}]`,
 		},
 		{
 			name: "empty batch",
@@ -287,12 +328,42 @@ func TestRPC(t *testing.T) {
 	}
 }
 
+func TestRawMessageResult(t *testing.T) {
+	server := jsonrpc.New()
+	server.DumpErrors = true
+	server.Register(jsonrpc.Methods{
+		"Cached": func(ctx context.Context) (interface{}, error) {
+			return json.RawMessage(`{"name":"Alice","tags":["a","b"]}`), nil
+		},
+		"Invalid": func(ctx context.Context) (interface{}, error) {
+			return json.RawMessage(`{"name":`), nil
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "Cached"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": {"name": "Alice", "tags": ["a", "b"]}}`)
+
+	resp = do(server, `{"id": 1, "method": "Invalid"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {
+			"name": "internal_error",
+			"message": "internal error",
+			"retryable": true,
+			"details": ["method returned invalid raw JSON"]
+		}
+	}`)
+}
+
 func TestErrorHiding(t *testing.T) {
 	server := jsonrpc.New()
 	server.Register(jsonrpc.Methods{
 		"Do": func(ctx context.Context) (interface{}, error) {
 			return nil, errors.New("an internal error occurred")
 		},
+		"Invalid": func(ctx context.Context) (interface{}, error) {
+			return nil, jsonrpc.InvalidParams("invalid params").Wrap(errors.New("secret detail"))
+		},
 	})
 
 	t.Run("DumpErrors=false", func(t *testing.T) {
@@ -301,7 +372,8 @@ func TestErrorHiding(t *testing.T) {
 		assert.JSONEqual(t, resp.Body.String(), `{
 			"error": {
 				"name": "internal_error",
-				"message": "internal error"
+				"message": "internal error",
+				"retryable": true
 			},
 			"id": 1
 		}`)
@@ -314,6 +386,7 @@ func TestErrorHiding(t *testing.T) {
 			"error": {
 				"name": "internal_error",
 				"message": "internal error",
+				"retryable": true,
 				"details": [
 					"an internal error occurred"
 				]
@@ -321,130 +394,2060 @@ func TestErrorHiding(t *testing.T) {
 			"id": 1
 		}`)
 	})
+
+	t.Run("DumpErrorNames", func(t *testing.T) {
+		server.DumpErrors = true
+		resp := do(server, `{"id": 1, "method": "Invalid"}`)
+		assert.JSONEqual(t, resp.Body.String(), `{
+			"error": {
+				"name": "invalid_params",
+				"message": "invalid params"
+			},
+			"id": 1
+		}`)
+
+		server.DumpErrorNames = []string{"invalid_params"}
+		defer func() { server.DumpErrorNames = nil }()
+		resp = do(server, `{"id": 1, "method": "Invalid"}`)
+		assert.JSONEqual(t, resp.Body.String(), `{
+			"error": {
+				"name": "invalid_params",
+				"message": "invalid params",
+				"details": ["secret detail"]
+			},
+			"id": 1
+		}`)
+		resp = do(server, `{"id": 1, "method": "Do"}`)
+		assert.JSONEqual(t, resp.Body.String(), `{
+			"error": {
+				"name": "internal_error",
+				"message": "internal error",
+				"retryable": true
+			},
+			"id": 1
+		}`)
+	})
 }
 
-func TestMiddleware(t *testing.T) {
+func TestMounted(t *testing.T) {
+	type contextKey struct{}
 	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Whoami": func(ctx context.Context) (interface{}, error) {
+			return jsonrpc.M{
+				"user": ctx.Value(contextKey{}),
+				"path": jsonrpc.RequestFromContext(ctx).URL.Path,
+			}, nil
+		},
+	})
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Middleware", "auth")
+			ctx := context.WithValue(r.Context(), contextKey{}, "alice")
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/rpc/", auth(http.StripPrefix("/rpc", server)))
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
 
-	var calls int
-	server.Use(func(next jsonrpc.Next) jsonrpc.Next {
-		return func(ctx context.Context, params interface{}) (interface{}, error) {
-			calls++
-			return next(ctx, params)
+	req := httptest.NewRequest(http.MethodPost, "/rpc/v1", strings.NewReader(`{"id": 1, "method": "Whoami"}`))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, w.Code, 200)
+	assert.Equal(t, w.Header().Get("X-Middleware"), "auth")
+	assert.JSONEqual(t, w.Body.String(), `{"id": 1, "result": {"user": "alice", "path": "/v1"}}`)
+
+	req = httptest.NewRequest(http.MethodGet, "/health", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, w.Body.String(), "ok")
+}
+
+func TestErrorMethod(t *testing.T) {
+	server := jsonrpc.New()
+	server.ErrorMethod = true
+	server.Register(jsonrpc.Methods{
+		"Do": func(ctx context.Context) (interface{}, error) {
+			return nil, errors.New("an internal error occurred")
+		},
+	})
+
+	resp := do(server, `[{"id": 1, "method": "Do"}, {"id": 2, "method": "Missing"}]`)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{
+			"error": {
+				"name": "internal_error",
+				"message": "internal error",
+				"retryable": true,
+				"method": "Do"
+			},
+			"id": 1
+		},
+		{
+			"error": {
+				"name": "method_not_found",
+				"message": "method not found: Missing",
+				"method": "Missing"
+			},
+			"id": 2
 		}
+	]`)
+
+	// Errors returned by methods are annotated without being modified, since
+	// they may be shared between requests.
+	errClosed := jsonrpc.Error("closed", "shop is closed")
+	server.Register(jsonrpc.Methods{
+		"Order": func(ctx context.Context) (interface{}, error) {
+			return nil, errClosed
+		},
 	})
+	resp = do(server, `{"id": 1, "method": "Order"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {"name": "closed", "message": "shop is closed", "method": "Order"}
+	}`)
+	b, err := json.Marshal(errClosed)
+	assert.Must(t, err)
+	assert.JSONEqual(t, string(b), `{"name": "closed", "message": "shop is closed"}`)
+}
+
+func TestPanicClassifier(t *testing.T) {
+	type badInput string
+	server := jsonrpc.New()
+	server.PanicClassifier = func(recovered interface{}) *jsonrpc.RPCError {
+		if msg, ok := recovered.(badInput); ok {
+			return jsonrpc.InvalidParams("bad input: %s", msg)
+		}
+		return nil
+	}
 	server.Register(jsonrpc.Methods{
-		"Hello": func(ctx context.Context, name string) (interface{}, error) {
-			return fmt.Sprintf("Hello, %s!", name), nil
+		"Parse": func(ctx context.Context) (interface{}, error) {
+			panic(badInput("unexpected token"))
+		},
+		"Crash": func(ctx context.Context) (interface{}, error) {
+			panic("boom")
 		},
 	})
 
-	resp := do(server, `{"id": 1, "method": "Hello", "params": "John"}`)
-	assert.Equal(t, resp.Result().StatusCode, 200)
-	assert.Equal(t, calls, 1)
+	resp := do(server, `[{"id": 1, "method": "Parse"}, {"id": 2, "method": "Crash"}]`)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{"id": 1, "error": {"name": "invalid_params", "message": "bad input: unexpected token"}},
+		{"id": 2, "error": {"name": "internal_error", "message": "internal error", "retryable": true}}
+	]`)
 }
 
-func TestMiddlewareGroups(t *testing.T) {
+func TestPanicWithError(t *testing.T) {
 	server := jsonrpc.New()
+	server.DumpErrors = true
+	server.Register(jsonrpc.Methods{
+		"RPCError": func(ctx context.Context) (interface{}, error) {
+			panic(jsonrpc.NotFound("no such order"))
+		},
+		"Error": func(ctx context.Context) (interface{}, error) {
+			panic(errors.New("broken"))
+		},
+		"String": func(ctx context.Context) (interface{}, error) {
+			panic("boom")
+		},
+	})
+
+	resp := do(server, `[
+		{"id": 1, "method": "RPCError"},
+		{"id": 2, "method": "Error"},
+		{"id": 3, "method": "String"}
+	]`)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{"id": 1, "error": {"name": "not_found", "message": "no such order"}},
+		{"id": 2, "error": {"name": "internal_error", "message": "internal error", "retryable": true, "details": ["broken"]}},
+		{"id": 3, "error": {"name": "internal_error", "message": "internal error", "retryable": true, "details": ["boom"]}}
+	]`)
+}
 
+func TestOnParseError(t *testing.T) {
 	var (
-		calls   int              // overall calls
-		g1      = server.Group() // group 1
-		g1Calls int              // group 1 calls
-		g2      = server.Group() // group 2
-		g2Calls int              // group 2 calls
-		noop    = jsonrpc.MethodFunc(func(context.Context) (interface{}, error) {
-			return nil, nil
-		})
+		gotRaw string
+		gotErr error
 	)
+	server := jsonrpc.New()
+	server.OnParseError = func(r *http.Request, raw []byte, err error) {
+		gotRaw, gotErr = string(raw), err
+	}
+	server.Register(jsonrpc.Methods{
+		"Ping": func(ctx context.Context) (interface{}, error) {
+			return "pong", nil
+		},
+	})
 
-	server.Use(func(next jsonrpc.Next) jsonrpc.Next {
-		return func(ctx context.Context, params interface{}) (interface{}, error) {
-			calls++
-			return next(ctx, params)
-		}
+	resp := do(server, `{"id": 1, "method": "Ping"`)
+	assert.Equal(t, resp.Result().StatusCode, 400)
+	assert.Equal(t, gotRaw, `{"id": 1, "method": "Ping"`)
+	var rpcErr *jsonrpc.RPCError
+	assert.Equal(t, errors.As(gotErr, &rpcErr), true)
+	assert.Equal(t, rpcErr.Name, "parse_error")
+
+	gotRaw, gotErr = "", nil
+	do(server, `{"id": 1, "method": "Ping"}`)
+	assert.Equal(t, gotRaw, "")
+	assert.Equal(t, gotErr, nil)
+}
+
+func TestLargeRequest(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Len": func(ctx context.Context, s string) (interface{}, error) {
+			return len(s), nil
+		},
 	})
-	g1.Use(func(next jsonrpc.Next) jsonrpc.Next {
-		return func(ctx context.Context, params interface{}) (interface{}, error) {
-			g1Calls++
-			return next(ctx, params)
+
+	big := strings.Repeat("x", 8<<20)
+	resp := do(server, `  {"id": 1, "method": "Len", "params": "`+big+`"}`+"\n")
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": 8388608}`)
+
+	resp = do(server, `{"id": 1, "method": "Len", "params": "abc"} x`)
+	assert.Equal(t, resp.Result().StatusCode, 400)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": null,
+		"error": {
+			"name": "parse_error",
+			"message": "cannot parse request: offset 45: invalid character 'x' after top-level value"
 		}
+	}`)
+}
+
+func TestBatchParseErrors(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Ping": func(ctx context.Context) (interface{}, error) {
+			return "pong", nil
+		},
 	})
-	g2.Use(func(next jsonrpc.Next) jsonrpc.Next {
-		return func(ctx context.Context, params interface{}) (interface{}, error) {
-			g2Calls++
-			return next(ctx, params)
+
+	t.Run("invalid element", func(t *testing.T) {
+		resp := do(server, `[
+			{"id": 1, "method": "Ping"},
+			{"id": 2, "method": 2},
+			{"id": true, "method": "Ping"},
+			3,
+			{"id": 5, "method": "Ping"}
+		]`)
+		assert.Equal(t, resp.Result().StatusCode, 200)
+		var got []struct {
+			ID     interface{}
+			Result interface{}
+			Error  *jsonrpc.RPCError
 		}
+		assert.Must(t, json.Unmarshal(resp.Body.Bytes(), &got))
+		assert.Equal(t, len(got), 5)
+		assert.Equal(t, got[0].Result, "pong")
+		assert.Equal(t, got[1].ID, 2.0)
+		assert.Equal(t, got[1].Error.Name, "parse_error")
+		assert.Equal(t, strings.HasPrefix(got[1].Error.Message, "cannot parse batch element 1: "), true)
+		assert.Equal(t, got[2].Error.Name, "invalid_request")
+		assert.Equal(t, got[3].ID, nil)
+		assert.Equal(t, got[3].Error.Name, "parse_error")
+		assert.Equal(t, strings.HasPrefix(got[3].Error.Message, "cannot parse batch element 3: "), true)
+		assert.Equal(t, got[4].Result, "pong")
 	})
-	g1.Register(jsonrpc.Methods{"G1": noop})
-	g2.Register(jsonrpc.Methods{"G2": noop})
 
-	resp := do(server, `{"id": 1, "method": "G1"}`)
-	assert.Equal(t, resp.Result().StatusCode, 200)
-	assert.Equal(t, calls, 1)
-	assert.Equal(t, g1Calls, 1)
-	assert.Equal(t, g2Calls, 0)
+	t.Run("invalid json", func(t *testing.T) {
+		resp := do(server, `[{"id": 1, "method": "Ping"}, {"id": 2, "method": }]`)
+		assert.Equal(t, resp.Result().StatusCode, 400)
+		assert.JSONEqual(t, resp.Body.String(), `{
+			"id": null,
+			"error": {"name": "parse_error", "message": "cannot parse batch element 1: offset 51: invalid character '}' looking for beginning of value"}
+		}`)
+	})
+}
 
-	resp = do(server, `{"id": 1, "method": "G2"}`)
-	assert.Equal(t, resp.Result().StatusCode, 200)
-	assert.Equal(t, calls, 2)
-	assert.Equal(t, g1Calls, 1)
-	assert.Equal(t, g2Calls, 1)
+// countingBody counts the bytes read from it.
+type countingBody struct {
+	r io.Reader
+	n int
 }
 
-func TestContext(t *testing.T) {
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.n += n
+	return n, err
+}
+
+func TestRejectMalformedBatches(t *testing.T) {
 	server := jsonrpc.New()
-	var (
-		gotMethod  string
-		gotRequest *http.Request
-	)
+	server.RejectMalformedBatches = true
 	server.Register(jsonrpc.Methods{
-		"Do": func(ctx context.Context) (interface{}, error) {
-			gotMethod = jsonrpc.MethodFromContext(ctx)
-			gotRequest = jsonrpc.RequestFromContext(ctx)
-			return nil, nil
+		"Ping": func(ctx context.Context) (interface{}, error) {
+			return "pong", nil
 		},
 	})
-	resp := do(server, `{"id": 1, "method": "Do"}`)
-	assert.Equal(t, resp.Result().StatusCode, 200)
-	assert.Equal(t, gotMethod, "Do")
-	assert.NotNil(t, gotRequest)
+
+	tests := []struct {
+		name string
+		body string
+		resp string
+	}{
+		{
+			name: "valid",
+			body: `[{"id": 1, "method": "Ping"}, {"id": 2, "method": "Ping"}]`,
+			resp: `[{"id": 1, "result": "pong"}, {"id": 2, "result": "pong"}]`,
+		},
+		{
+			name: "missing method",
+			body: `[{"id": 1, "method": "Ping"}, {"id": 2}]`,
+			resp: `{"id": null, "error": {"name": "invalid_request", "message": "invalid batch element 1: method required"}}`,
+		},
+		{
+			name: "invalid id",
+			body: `[{"id": true, "method": "Ping"}]`,
+			resp: `{"id": null, "error": {"name": "invalid_request", "message": "invalid batch element 0: id must be number or string"}}`,
+		},
+		{
+			name: "truncated",
+			body: `[{"id": 1, "method": "Ping"}, {"id": 2,`,
+			resp: `{"id": null, "error": {"name": "parse_error", "message": "cannot parse batch element 1: offset 39: unexpected end of JSON input"}}`,
+		},
+		{
+			name: "trailing data",
+			body: `[{"id": 1, "method": "Ping"}] x`,
+			resp: `{"id": null, "error": {"name": "parse_error", "message": "cannot parse request: offset 31: invalid character 'x' after top-level value"}}`,
+		},
+		{
+			name: "empty",
+			body: `[]`,
+			resp: `{"id": null, "error": {"name": "invalid_request", "message": "empty batch"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := do(server, tt.body)
+			assert.JSONEqual(t, resp.Body.String(), tt.resp)
+
+			// Dispatch rejects the same batches.
+			b, err := server.Dispatch(context.Background(), []byte(tt.body))
+			assert.Must(t, err)
+			assert.JSONEqual(t, string(b), tt.resp)
+		})
+	}
+
+	t.Run("stops reading", func(t *testing.T) {
+		body := &countingBody{r: strings.NewReader(`[{"id": 1}, ` + strings.Repeat(`{"id": 2, "method": "Ping"}, `, 100000) + `]`)}
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/", body))
+		assert.Equal(t, resp.Code, 400)
+		assert.Equal(t, body.n < 100000, true)
+	})
 }
 
-func TestPreventDupeMethods(t *testing.T) {
-	noop := func(context.Context) (interface{}, error) { return nil, nil }
-	h := jsonrpc.New()
-	h.Register(jsonrpc.Methods{
-		"Do": noop,
+func TestEchoMalformedIDs(t *testing.T) {
+	server := jsonrpc.New()
+	server.EchoMalformedIDs = true
+	server.Register(jsonrpc.Methods{
+		"Ping": func(ctx context.Context) (interface{}, error) {
+			return "pong", nil
+		},
 	})
 
-	var gotPanic interface{}
-	(func() {
-		defer func() { gotPanic = recover() }()
-		h.Group().Register(jsonrpc.Methods{
-			"Do": noop,
-		})
-	})()
-	assert.Equal(t, gotPanic, "jsonrpc: method already registered: Do")
+	tests := []struct {
+		body string
+		id   string
+	}{
+		{`{"id": 1, "method": 2}`, `1`},
+		{`{"params": {"a": [1]}, "id": "abc", "method": 5}`, `"abc"`},
+		{`{"id": 1, "method": "Ping",`, `1`},
+		{`{"method": "Ping", "params": {, "id": 1}`, `null`},
+		{`{"id": {}, "method": 2}`, `null`},
+		{`[{"id": 1, "method": }]`, `null`},
+	}
+	for _, tt := range tests {
+		resp := do(server, tt.body)
+		assert.Equal(t, resp.Result().StatusCode, 400)
+		var got struct{ ID json.RawMessage }
+		assert.Must(t, json.Unmarshal(resp.Body.Bytes(), &got))
+		assert.Equal(t, string(got.ID), tt.id)
+	}
+
+	got, err := server.Dispatch(context.Background(), []byte(`{"id": 2, "method": 2}`))
+	assert.Must(t, err)
+	var resp struct {
+		ID    interface{}
+		Error *jsonrpc.RPCError
+	}
+	assert.Must(t, json.Unmarshal(got, &resp))
+	assert.Equal(t, resp.ID, 2.0)
+	assert.Equal(t, resp.Error.Name, "parse_error")
 }
 
-func TestPreventMiddlewareAfterRegister(t *testing.T) {
-	noop := func(context.Context) (interface{}, error) { return nil, nil }
-	h := jsonrpc.New()
-	h.Register(jsonrpc.Methods{
-		"Do": noop,
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("cannot marshal")
+}
+
+func TestUnencodableResult(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Func": func(ctx context.Context) (interface{}, error) {
+			return func() {}, nil
+		},
+		"Failing": func(ctx context.Context) (interface{}, error) {
+			jsonrpc.AddWarning(ctx, "failing")
+			return failingMarshaler{}, nil
+		},
+		"Ping": func(ctx context.Context) (interface{}, error) {
+			return "pong", nil
+		},
 	})
 
-	var gotPanic interface{}
-	(func() {
-		defer func() { gotPanic = recover() }()
-		h.Use(func(next jsonrpc.Next) jsonrpc.Next {
-			return func(ctx context.Context, params interface{}) (interface{}, error) {
-				return next(ctx, params)
-			}
-		})
-	})()
+	resp := do(server, `{"id": 1, "method": "Func"}`)
+	assert.Equal(t, resp.Result().StatusCode, 500)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {
+			"name": "internal_error",
+			"message": "internal error",
+			"retryable": true
+		}
+	}`)
 
+	resp = do(server, `[
+		{"id": 1, "method": "Ping"},
+		{"id": 2, "method": "Failing"},
+		{"method": "Func"}
+	]`)
+	assert.Equal(t, resp.Result().StatusCode, 200)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{"id": 1, "result": "pong"},
+		{
+			"id": 2,
+			"error": {
+				"name": "internal_error",
+				"message": "internal error",
+				"retryable": true
+			},
+			"warnings": ["failing"]
+		}
+	]`)
+}
+
+func TestHTTPMethod(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Ping": func(ctx context.Context) (interface{}, error) {
+			return "pong", nil
+		},
+	})
+
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodOptions} {
+		req := httptest.NewRequest(method, "/", strings.NewReader(`{"id": 1, "method": "Ping"}`))
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Result().StatusCode, 405)
+		assert.Equal(t, resp.Header().Get("Allow"), "POST")
+		assert.JSONEqual(t, resp.Body.String(), `{
+			"id": null,
+			"error": {"name": "invalid_request", "message": "HTTP method `+method+` not allowed"}
+		}`)
+	}
+
+	server.CORS = &jsonrpc.CORSConfig{AllowedOrigins: []string{"*"}}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	assert.Equal(t, resp.Result().StatusCode, 405)
+	assert.Equal(t, resp.Header().Get("Allow"), "POST, OPTIONS")
+}
+
+func TestDispatch(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Upper": func(ctx context.Context, s string) (interface{}, error) {
+			assert.Equal(t, jsonrpc.RequestFromContext(ctx), (*http.Request)(nil))
+			return strings.ToUpper(s), nil
+		},
+		"Func": func(ctx context.Context) (interface{}, error) {
+			return func() {}, nil
+		},
+	})
+	ctx := context.Background()
+
+	resp, err := server.Dispatch(ctx, []byte(`{"id": 1, "method": "Upper", "params": "a"}`))
+	assert.Must(t, err)
+	assert.Equal(t, string(resp), `{"result":"A","id":1}`)
+
+	resp, err = server.Dispatch(ctx, []byte(`[{"id": 1, "method": "Upper", "params": "a"}, {"id": 2, "method": "Missing"}]`))
+	assert.Must(t, err)
+	assert.JSONEqual(t, string(resp), `[
+		{"id": 1, "result": "A"},
+		{"id": 2, "error": {"name": "method_not_found", "message": "method not found: Missing"}}
+	]`)
+
+	resp, err = server.Dispatch(ctx, []byte(`[{"method": "Upper", "params": "a"}]`))
+	assert.Must(t, err)
+	assert.Equal(t, resp, []byte(nil))
+
+	resp, err = server.Dispatch(ctx, []byte(`[]`))
+	assert.Must(t, err)
+	assert.JSONEqual(t, string(resp), `{"id": null, "error": {"name": "invalid_request", "message": "empty batch"}}`)
+
+	resp, err = server.Dispatch(ctx, []byte(`[{"id": 1, "method": "Func"}, {"id": 2, "method": "Upper", "params": "b"}]`))
+	assert.Must(t, err)
+	var got []struct {
+		Result interface{}
+		Error  struct{ Name string }
+		ID     int
+	}
+	assert.Must(t, json.Unmarshal(resp, &got))
+	assert.Equal(t, len(got), 2)
+	assert.Equal(t, got[0].Error.Name, "internal_error")
+	assert.Equal(t, got[0].ID, 1)
+	assert.Equal(t, got[1].Result, "B")
+}
+
+func TestLocalizer(t *testing.T) {
+	catalog := map[string]map[string]string{
+		"fr": {"customer_not_found": "client %d introuvable"},
+	}
+	server := jsonrpc.New()
+	server.Localizer = func(lang, key string, args ...interface{}) string {
+		if msg, ok := catalog[lang][key]; ok {
+			return fmt.Sprintf(msg, args...)
+		}
+		return fmt.Sprintf("customer %d not found", args...)
+	}
+	server.Register(jsonrpc.Methods{
+		"GetCustomer": func(ctx context.Context, id int) (interface{}, error) {
+			return nil, jsonrpc.NotFound("customer not found").Key("customer_not_found", id)
+		},
+	})
+
+	doLang := func(lang string) string {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(
+			`{"id": 1, "method": "GetCustomer", "params": 42}`,
+		))
+		req.Header.Set("Accept-Language", lang)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	assert.JSONEqual(t, doLang("fr-CH;q=0.5, fr"), `{
+		"id": 1,
+		"error": {"name": "not_found", "message": "client 42 introuvable"}
+	}`)
+	assert.JSONEqual(t, doLang(""), `{
+		"id": 1,
+		"error": {"name": "not_found", "message": "customer 42 not found"}
+	}`)
+
+	// Errors returned by methods are localized without being modified, since
+	// they may be shared between requests.
+	errNotFound := jsonrpc.NotFound("customer not found").Key("customer_not_found", 7)
+	server.Register(jsonrpc.Methods{
+		"GetDefaultCustomer": func(ctx context.Context) (interface{}, error) {
+			return nil, errNotFound
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(
+		`{"id": 1, "method": "GetDefaultCustomer"}`,
+	))
+	req.Header.Set("Accept-Language", "fr")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.JSONEqual(t, w.Body.String(), `{
+		"id": 1,
+		"error": {"name": "not_found", "message": "client 7 introuvable"}
+	}`)
+	assert.Equal(t, errNotFound.Message, "customer not found")
+}
+
+func TestTimeEncoder(t *testing.T) {
+	now := time.Date(2000, 1, 1, 1, 0, 0, 0, time.UTC)
+	server := jsonrpc.New()
+	server.TimeEncoder = func(t time.Time) interface{} {
+		return t.UnixNano() / int64(time.Millisecond)
+	}
+	server.Register(jsonrpc.Methods{
+		"Now": func(ctx context.Context) (interface{}, error) {
+			return now, nil
+		},
+		"Nested": func(ctx context.Context) (interface{}, error) {
+			return jsonrpc.M{
+				"created_at": &now,
+				"history":    []interface{}{now, "other"},
+			}, nil
+		},
+		"Struct": func(ctx context.Context) (interface{}, error) {
+			return struct {
+				CreatedAt time.Time `json:"created_at"`
+			}{now}, nil
+		},
+	})
+
+	resp := do(server, `[
+		{"id": 1, "method": "Now"},
+		{"id": 2, "method": "Nested"},
+		{"id": 3, "method": "Struct"}
+	]`)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{"id": 1, "result": 946688400000},
+		{"id": 2, "result": {"created_at": 946688400000, "history": [946688400000, "other"]}},
+		{"id": 3, "result": {"created_at": "2000-01-01T01:00:00Z"}}
+	]`)
+}
+
+func TestErrorStatusMode(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Internal": func(ctx context.Context) (interface{}, error) {
+			return nil, errors.New("boom")
+		},
+		"NotFound": func(ctx context.Context) (interface{}, error) {
+			return nil, jsonrpc.NotFound("not found")
+		},
+		"Custom": func(ctx context.Context) (interface{}, error) {
+			return nil, jsonrpc.Error("invalid_customer", "invalid customer")
+		},
+		"Conflict": func(ctx context.Context) (interface{}, error) {
+			return nil, jsonrpc.Error("conflict", "conflict")
+		},
+	})
+
+	tests := []struct {
+		mode   jsonrpc.ErrorStatusMode
+		req    string
+		status int
+	}{
+		{jsonrpc.AlwaysOK, `{"id": 1, "method": "Internal"}`, 200},
+		{jsonrpc.AlwaysOK, `{"id": 1, "method": "NotFound"}`, 200},
+		{jsonrpc.AlwaysOK, `{"method": "NotFound"}`, 400},
+		{jsonrpc.MapFromName, `{"id": 1, "method": "Internal"}`, 500},
+		{jsonrpc.MapFromName, `{"id": 1, "method": "NotFound"}`, 404},
+		{jsonrpc.MapFromName, `{"id": 1, "method": "Missing"}`, 404},
+		{jsonrpc.MapFromName, `{"id": 1, "method": "Custom"}`, 400},
+		{jsonrpc.MapFromName, `{"id": 1, "method": "Conflict"}`, 409},
+		{jsonrpc.MapFromName, `[{"id": 1, "method": "Internal"}, {"id": 2, "method": "Internal"}]`, 200},
+	}
+	server.ErrorStatuses = map[string]int{"conflict": 409}
+	for _, tt := range tests {
+		server.ErrorStatusMode = tt.mode
+		resp := do(server, tt.req)
+		if got := resp.Result().StatusCode; got != tt.status {
+			t.Errorf("mode %d: %s: got %d, want %d", tt.mode, tt.req, got, tt.status)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	server := jsonrpc.New()
+
+	var calls int
+	server.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			calls++
+			return next(ctx, params)
+		}
+	})
+	server.Register(jsonrpc.Methods{
+		"Hello": func(ctx context.Context, name string) (interface{}, error) {
+			return fmt.Sprintf("Hello, %s!", name), nil
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "Hello", "params": "John"}`)
+	assert.Equal(t, resp.Result().StatusCode, 200)
+	assert.Equal(t, calls, 1)
+}
+
+func TestMiddlewareGroups(t *testing.T) {
+	server := jsonrpc.New()
+
+	var (
+		calls   int              // overall calls
+		g1      = server.Group() // group 1
+		g1Calls int              // group 1 calls
+		g2      = server.Group() // group 2
+		g2Calls int              // group 2 calls
+		noop    = jsonrpc.MethodFunc(func(context.Context) (interface{}, error) {
+			return nil, nil
+		})
+	)
+
+	server.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			calls++
+			return next(ctx, params)
+		}
+	})
+	g1.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			g1Calls++
+			return next(ctx, params)
+		}
+	})
+	g2.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			g2Calls++
+			return next(ctx, params)
+		}
+	})
+	g1.Register(jsonrpc.Methods{"G1": noop})
+	g2.Register(jsonrpc.Methods{"G2": noop})
+
+	resp := do(server, `{"id": 1, "method": "G1"}`)
+	assert.Equal(t, resp.Result().StatusCode, 200)
+	assert.Equal(t, calls, 1)
+	assert.Equal(t, g1Calls, 1)
+	assert.Equal(t, g2Calls, 0)
+
+	resp = do(server, `{"id": 1, "method": "G2"}`)
+	assert.Equal(t, resp.Result().StatusCode, 200)
+	assert.Equal(t, calls, 2)
+	assert.Equal(t, g1Calls, 1)
+	assert.Equal(t, g2Calls, 1)
+}
+
+func TestMiddlewareResult(t *testing.T) {
+	type user struct {
+		Name     string `json:"name"`
+		Password string `json:"password,omitempty"`
+	}
+	server := jsonrpc.New()
+
+	var gotResult interface{}
+	server.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			result, err := next(ctx, params)
+			if err != nil {
+				return nil, err
+			}
+			gotResult = result
+			return jsonrpc.M{"data": result}, nil
+		}
+	})
+	server.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			result, err := next(ctx, params)
+			if u, ok := result.(*user); ok {
+				u.Password = ""
+			}
+			return result, err
+		}
+	})
+	server.Register(jsonrpc.Methods{
+		"GetUser": func(ctx context.Context) (interface{}, error) {
+			return &user{Name: "Alice", Password: "secret"}, nil
+		},
+		"Fail": func(ctx context.Context) (interface{}, error) {
+			return nil, errors.New("an internal error occurred")
+		},
+		"NotFound": func(ctx context.Context) (interface{}, error) {
+			return nil, jsonrpc.NotFound("user not found")
+		},
+	})
+
+	t.Run("transform", func(t *testing.T) {
+		resp := do(server, `{"id": 1, "method": "GetUser"}`)
+		assert.Equal(t, resp.Result().StatusCode, 200)
+		assert.JSONEqual(t, resp.Body.String(), `{
+			"id": 1,
+			"result": {"data": {"name": "Alice"}}
+		}`)
+		assert.Equal(t, gotResult, &user{Name: "Alice"})
+	})
+
+	t.Run("internal error", func(t *testing.T) {
+		resp := do(server, `{"id": 1, "method": "Fail"}`)
+		assert.JSONEqual(t, resp.Body.String(), `{
+			"id": 1,
+			"error": {"name": "internal_error", "message": "internal error", "retryable": true}
+		}`)
+	})
+
+	t.Run("rpc error", func(t *testing.T) {
+		resp := do(server, `{"id": 1, "method": "NotFound"}`)
+		assert.JSONEqual(t, resp.Body.String(), `{
+			"id": 1,
+			"error": {"name": "not_found", "message": "user not found"}
+		}`)
+	})
+}
+
+func TestMiddlewareErrorTransform(t *testing.T) {
+	server := jsonrpc.New()
+	server.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			result, err := next(ctx, params)
+			if err == nil {
+				return nil, errors.New("rejected by middleware")
+			}
+			return result, err
+		}
+	})
+	server.Register(jsonrpc.Methods{
+		"Do": func(ctx context.Context) (interface{}, error) {
+			return "ok", nil
+		},
+	})
+
+	server.DumpErrors = true
+	resp := do(server, `{"id": 1, "method": "Do"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {
+			"name": "internal_error",
+			"message": "internal error",
+			"retryable": true,
+			"details": ["rejected by middleware"]
+		}
+	}`)
+}
+
+func TestErrorHandlers(t *testing.T) {
+	errNoRows := errors.New("no rows")
+	server := jsonrpc.New()
+
+	var calls []string
+	server.UseErrorHandler(func(ctx context.Context, err error) error {
+		calls = append(calls, "root")
+		if err == errNoRows {
+			return jsonrpc.NotFound("record not found")
+		}
+		return err
+	})
+	g := server.Group()
+	g.UseErrorHandler(func(ctx context.Context, err error) error {
+		calls = append(calls, "group")
+		return err
+	})
+	g.Register(jsonrpc.Methods{
+		"Find": func(ctx context.Context) (interface{}, error) {
+			return nil, errNoRows
+		},
+		"OK": func(ctx context.Context) (interface{}, error) {
+			return "ok", nil
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "Find"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {"name": "not_found", "message": "record not found"}
+	}`)
+	assert.Equal(t, calls, []string{"group", "root"})
+
+	calls = nil
+	resp = do(server, `{"id": 1, "method": "OK"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "ok"}`)
+	assert.Equal(t, len(calls), 0)
+}
+
+func TestMapErrors(t *testing.T) {
+	errDeclined := errors.New("declined")
+	server := jsonrpc.New()
+	server.MapErrors(func(err error) error {
+		if rpcErr, ok := err.(*jsonrpc.RPCError); ok {
+			rpcErr.Message += " (mapped by root)"
+		}
+		return err
+	})
+
+	var seen error
+	payments := server.Group()
+	payments.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			result, err := next(ctx, params)
+			seen = err
+			return result, err
+		}
+	})
+	payments.MapErrors(func(err error) error {
+		if err == errDeclined {
+			return jsonrpc.Error("payment_declined", "payment declined")
+		}
+		return err
+	})
+	payments.Register(jsonrpc.Methods{
+		"Pay": func(ctx context.Context) (interface{}, error) {
+			return nil, errDeclined
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "Pay"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {"name": "payment_declined", "message": "payment declined (mapped by root)"}
+	}`)
+	assert.Equal(t, seen.Error(), "jsonrpc: payment declined: payment declined (mapped by root)")
+}
+
+func TestContext(t *testing.T) {
+	server := jsonrpc.New()
+	var (
+		gotMethod  string
+		gotRequest *http.Request
+	)
+	server.Register(jsonrpc.Methods{
+		"Do": func(ctx context.Context) (interface{}, error) {
+			gotMethod = jsonrpc.MethodFromContext(ctx)
+			gotRequest = jsonrpc.RequestFromContext(ctx)
+			return nil, nil
+		},
+	})
+	resp := do(server, `{"id": 1, "method": "Do"}`)
+	assert.Equal(t, resp.Result().StatusCode, 200)
+	assert.Equal(t, gotMethod, "Do")
+	assert.NotNil(t, gotRequest)
+}
+
+func TestContextKeyCollisions(t *testing.T) {
+	var (
+		gotMethod  string
+		gotRequest *http.Request
+	)
+	server := jsonrpc.New()
+
+	// Values added by others under keys with the same underlying values as
+	// the handler's keys don't collide with them.
+	server.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			for i := 0; i < 4; i++ {
+				ctx = context.WithValue(ctx, i, "other")
+			}
+			return next(ctx, params)
+		}
+	})
+	server.Register(jsonrpc.Methods{
+		"Other": func(ctx context.Context) (interface{}, error) {
+			gotMethod = jsonrpc.MethodFromContext(ctx)
+			gotRequest = jsonrpc.RequestFromContext(ctx)
+			return nil, nil
+		},
+	})
+	do(server, `{"id": 1, "method": "Other"}`)
+	assert.Equal(t, gotMethod, "Other")
+	assert.NotNil(t, gotRequest)
+}
+
+func TestStream(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Count": func(ctx context.Context, n int, emit jsonrpc.Emit) (interface{}, error) {
+			for i := 1; i <= n; i++ {
+				if err := emit(i); err != nil {
+					return nil, err
+				}
+			}
+			return "done", nil
+		},
+	})
+
+	t.Run("single", func(t *testing.T) {
+		resp := do(server, `{"id": 1, "method": "Count", "params": 2}`)
+		assert.Equal(t, resp.Result().StatusCode, 200)
+		assert.Equal(t, resp.Header().Get("content-type"), "application/x-ndjson; charset=utf-8")
+		assert.Equal(t, resp.Flushed, true)
+		assert.Equal(t, resp.Body.String(), strings.Join([]string{
+			`{"method":"Count","params":1}`,
+			`{"method":"Count","params":2}`,
+			`{"result":"done","id":1}`,
+			``,
+		}, "\n"))
+	})
+
+	t.Run("ids", func(t *testing.T) {
+		server.IDGenerator = &jsonrpc.CounterIDGenerator{}
+		defer func() { server.IDGenerator = nil }()
+		resp := do(server, `{"id": 1, "method": "Count", "params": 2}`)
+		assert.Equal(t, resp.Body.String(), strings.Join([]string{
+			`{"method":"Count","params":1,"id":"1"}`,
+			`{"method":"Count","params":2,"id":"2"}`,
+			`{"result":"done","id":1}`,
+			``,
+		}, "\n"))
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		resp := do(server, `[
+			{"id": 1, "method": "Count", "params": 2},
+			{"id": 2, "method": "Count", "params": 2}
+		]`)
+		assert.JSONEqual(t, resp.Body.String(), `[
+			{"id": 1, "error": {"name": "invalid_request", "message": "method Count cannot be called in a batch"}},
+			{"id": 2, "error": {"name": "invalid_request", "message": "method Count cannot be called in a batch"}}
+		]`)
+	})
+}
+
+func TestStreamEmitAfterResponse(t *testing.T) {
+	defer jsonrpctest.AssertNoLeaks(t)()
+
+	var (
+		responded = make(chan struct{})
+		emitErr   = make(chan error)
+	)
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Background": func(ctx context.Context, emit jsonrpc.Emit) (interface{}, error) {
+			go func() {
+				<-responded
+				emitErr <- emit("late")
+			}()
+			return "done", nil
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "Background"}`)
+	close(responded)
+	assert.Equal(t, <-emitErr, jsonrpc.ErrStreamClosed)
+	assert.Equal(t, resp.Body.String(), `{"result":"done","id":1}`+"\n")
+}
+
+func TestWarnings(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Cached": func(ctx context.Context) (interface{}, error) {
+			jsonrpc.AddWarning(ctx, "used cached data")
+			jsonrpc.AddWarning(ctx, "cache age: %ds", 30)
+			return "ok", nil
+		},
+		"Plain": func(ctx context.Context) (interface{}, error) {
+			return "ok", nil
+		},
+	})
+
+	resp := do(server, `[
+		{"id": 1, "method": "Cached"},
+		{"id": 2, "method": "Plain"}
+	]`)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{"id": 1, "result": "ok", "warnings": ["used cached data", "cache age: 30s"]},
+		{"id": 2, "result": "ok"}
+	]`)
+
+	// Outside of a request, AddWarning is a no-op.
+	jsonrpc.AddWarning(context.Background(), "ignored")
+}
+
+func TestResponseMeta(t *testing.T) {
+	server := jsonrpc.New()
+	server.Meta = jsonrpc.M{"version": "1.2.3"}
+	server.Register(jsonrpc.Methods{
+		"Old": func(ctx context.Context) (interface{}, error) {
+			jsonrpc.SetResponseMeta(ctx, "deprecated", true)
+			jsonrpc.SetResponseMeta(ctx, "version", "1.2.4")
+			return "ok", nil
+		},
+		"New": func(ctx context.Context) (interface{}, error) {
+			return "ok", nil
+		},
+	})
+
+	resp := do(server, `[
+		{"id": 1, "method": "Old"},
+		{"id": 2, "method": "New"}
+	]`)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{"id": 1, "result": "ok", "meta": {"version": "1.2.4", "deprecated": true}},
+		{"id": 2, "result": "ok", "meta": {"version": "1.2.3"}}
+	]`)
+	assert.Equal(t, server.Meta, jsonrpc.M{"version": "1.2.3"})
+
+	// SetResponseMeta is a no-op outside of a request.
+	jsonrpc.SetResponseMeta(context.Background(), "ignored", true)
+}
+
+func TestBatchInfo(t *testing.T) {
+	type batchInfo struct {
+		Index, Total int
+		OK           bool
+	}
+	var got []batchInfo
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Do": func(ctx context.Context) (interface{}, error) {
+			index, total, ok := jsonrpc.BatchInfoFromContext(ctx)
+			got = append(got, batchInfo{index, total, ok})
+			return nil, nil
+		},
+	})
+
+	do(server, `{"id": 1, "method": "Do"}`)
+	assert.Equal(t, got, []batchInfo{{0, 0, false}})
+
+	got = nil
+	do(server, `[{"id": 1, "method": "Do"}, {"id": 2, "method": "Do"}]`)
+	assert.Equal(t, got, []batchInfo{{0, 2, true}, {1, 2, true}})
+}
+
+func TestBatchOrder(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Sleep": func(ctx context.Context, ms int) (interface{}, error) {
+			time.Sleep(time.Duration(ms) * time.Millisecond)
+			return ms, nil
+		},
+	})
+
+	// Later requests finish sooner, and ids are out of order, but responses
+	// must match the order of the requests, including across concurrent
+	// batches.
+	bodies := make([]string, 10)
+	var wg sync.WaitGroup
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = do(server, `[
+				{"id": "c", "method": "Sleep", "params": 3},
+				{"id": 9, "method": "Sleep", "params": 2},
+				{"id": "a", "method": "Sleep", "params": 1},
+				{"id": 1, "method": "Sleep", "params": 0}
+			]`).Body.String()
+		}(i)
+	}
+	wg.Wait()
+	for _, body := range bodies {
+		assert.JSONEqual(t, body, `[
+			{"id": "c", "result": 3},
+			{"id": 9, "result": 2},
+			{"id": "a", "result": 1},
+			{"id": 1, "result": 0}
+		]`)
+	}
+
+	// A batch of one is answered with an array of one, even if it fails.
+	resp := do(server, `[{"id": 1, "method": "Sleep", "params": 0}]`)
+	assert.JSONEqual(t, resp.Body.String(), `[{"id": 1, "result": 0}]`)
+	resp = do(server, `[{"id": 1, "method": "Missing"}]`)
+	assert.Equal(t, resp.Code, 200)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{"id": 1, "error": {"name": "method_not_found", "message": "method not found: Missing"}}
+	]`)
+}
+
+func TestBatchCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Cancel": func(ctx context.Context) (interface{}, error) {
+			calls++
+			cancel()
+			return "ok", nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[
+		{"id": 1, "method": "Cancel"},
+		{"id": 2, "method": "Cancel"},
+		{"id": 3, "method": "Cancel"}
+	]`)).WithContext(ctx)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+
+	assert.Equal(t, calls, 1)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{"id": 1, "result": "ok"},
+		{"id": 2, "error": {"name": "request_cancelled", "message": "request cancelled"}},
+		{"id": 3, "error": {"name": "request_cancelled", "message": "request cancelled"}}
+	]`)
+}
+
+func TestContextErrors(t *testing.T) {
+	defer jsonrpctest.AssertNoLeaks(t)()
+
+	server := jsonrpc.New()
+	server.ErrorStatusMode = jsonrpc.MapFromName
+	server.Register(jsonrpc.Methods{
+		"Wait": func(ctx context.Context) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	})
+	send := func(ctx context.Context) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id": 1, "method": "Wait"}`)).WithContext(ctx)
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		resp := send(ctx)
+		assert.Equal(t, resp.Code, 504)
+		assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "error": {"name": "timeout", "message": "request timed out", "retryable": true}}`)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(time.Millisecond, cancel)
+		resp := send(ctx)
+		assert.Equal(t, resp.Code, 499)
+		assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "error": {"name": "request_cancelled", "message": "request cancelled"}}`)
+	})
+
+	t.Run("deadline set by middleware", func(t *testing.T) {
+		server := jsonrpc.New()
+		server.Use(func(next jsonrpc.Next) jsonrpc.Next {
+			return func(ctx context.Context, params interface{}) (interface{}, error) {
+				ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+				defer cancel()
+				return next(ctx, params)
+			}
+		})
+		server.Register(jsonrpc.Methods{
+			"Wait": func(ctx context.Context) (interface{}, error) {
+				<-ctx.Done()
+				return nil, fmt.Errorf("waiting: %w", ctx.Err())
+			},
+		})
+		resp := do(server, `{"id": 1, "method": "Wait"}`)
+		var got struct {
+			Error struct{ Name string }
+		}
+		assert.Must(t, json.Unmarshal(resp.Body.Bytes(), &got))
+		assert.Equal(t, got.Error.Name, "timeout")
+	})
+}
+
+type dryRunParams struct {
+	Name string `json:"name"`
+}
+
+func (p *dryRunParams) Validate() error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func TestContextDeadline(t *testing.T) {
+	deadline := time.Now().Add(time.Hour)
+	methodDeadline := time.Now().Add(time.Minute)
+
+	server := jsonrpc.New()
+	g := server.Group()
+	g.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			ctx, cancel := context.WithDeadline(ctx, methodDeadline)
+			defer cancel()
+			return next(ctx, params)
+		}
+	})
+	var got time.Time
+	deadlineMethod := func(ctx context.Context) (interface{}, error) {
+		got, _ = ctx.Deadline()
+		return nil, nil
+	}
+	server.Register(jsonrpc.Methods{"Deadline": deadlineMethod})
+	g.Register(jsonrpc.Methods{"TimedDeadline": deadlineMethod})
+
+	send := func(method string) {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+		body := fmt.Sprintf(`{"id": 1, "method": %q}`, method)
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)).WithContext(ctx)
+		server.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send("Deadline")
+	assert.Equal(t, got.Equal(deadline), true)
+	send("TimedDeadline")
+	assert.Equal(t, got.Equal(methodDeadline), true)
+}
+
+func TestDryRun(t *testing.T) {
+	var calls int
+	server := jsonrpc.New()
+	server.AllowDryRun = true
+	server.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			if strings.HasPrefix(jsonrpc.MethodFromContext(ctx), "admin.") {
+				return nil, jsonrpc.Unauthorized("admin only")
+			}
+			return next(ctx, params)
+		}
+	})
+	server.Register(jsonrpc.Methods{
+		"Upper": func(ctx context.Context, s string) (interface{}, error) {
+			calls++
+			return strings.ToUpper(s), nil
+		},
+		"Create": func(ctx context.Context, p *dryRunParams) (interface{}, error) {
+			calls++
+			return p.Name, nil
+		},
+		"admin.Delete": func(ctx context.Context) (interface{}, error) {
+			calls++
+			return "deleted", nil
+		},
+	})
+
+	doDryRun := func(body string) string {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("X-Dry-Run", "true")
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	assert.JSONEqual(t, doDryRun(`[
+		{"id": 1, "method": "Upper", "params": "a"},
+		{"id": 2, "method": "Upper", "params": 1},
+		{"id": 3, "method": "Lower", "params": "a"},
+		{"id": 4, "method": "Create", "params": {"name": "a"}},
+		{"id": 5, "method": "Create", "params": {}},
+		{"id": 6, "method": "admin.Delete"}
+	]`), `[
+		{"id": 1},
+		{
+			"id": 2,
+			"error": {
+				"name": "parse_error",
+				"message": "cannot parse params: offset 1: cannot unmarshal number as string"
+			}
+		},
+		{
+			"id": 3,
+			"error": {"name": "method_not_found", "message": "method not found: Lower"}
+		},
+		{"id": 4},
+		{
+			"id": 5,
+			"error": {"name": "invalid_params", "message": "name is required"}
+		},
+		{
+			"id": 6,
+			"error": {"name": "unauthorized", "message": "admin only"}
+		}
+	]`)
+	assert.Equal(t, calls, 0)
+
+	// Without AllowDryRun, the header is ignored.
+	server.AllowDryRun = false
+	assert.JSONEqual(t, doDryRun(`{"id": 1, "method": "Upper", "params": "a"}`),
+		`{"id": 1, "result": "A"}`)
+	assert.Equal(t, calls, 1)
+}
+
+func TestMaxParamsDepth(t *testing.T) {
+	server := jsonrpc.New()
+	server.MaxParamsDepth = 2
+	server.Register(jsonrpc.Methods{
+		"Echo": func(ctx context.Context, val interface{}) (interface{}, error) {
+			return val, nil
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "Echo", "params": {"a": [1]}}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": {"a": [1]}}`)
+
+	resp = do(server, `{"id": 1, "method": "Echo", "params": {"a": [[1]]}}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {"name": "invalid_params", "message": "params too deeply nested"}
+	}`)
+}
+
+func TestRejectUnexpectedParams(t *testing.T) {
+	server := jsonrpc.New()
+	server.RejectUnexpectedParams = true
+	server.Register(jsonrpc.Methods{
+		"Ping": func(ctx context.Context) (interface{}, error) {
+			return "pong", nil
+		},
+		"Echo": func(ctx context.Context, s string) (interface{}, error) {
+			return s, nil
+		},
+	})
+
+	resp := do(server, `[
+		{"id": 1, "method": "Ping"},
+		{"id": 2, "method": "Ping", "params": null},
+		{"id": 3, "method": "Ping", "params": {"a": 1}},
+		{"id": 4, "method": "Ping", "params": []},
+		{"id": 5, "method": "Echo", "params": "hi"}
+	]`)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{"id": 1, "result": "pong"},
+		{"id": 2, "result": "pong"},
+		{"id": 3, "error": {"name": "invalid_params", "message": "method Ping takes no parameters"}},
+		{"id": 4, "error": {"name": "invalid_params", "message": "method Ping takes no parameters"}},
+		{"id": 5, "result": "hi"}
+	]`)
+}
+
+func TestFieldSelection(t *testing.T) {
+	type user struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+		Age   int    `json:"age"`
+	}
+	server := jsonrpc.New()
+	server.AllowFieldSelection = true
+	server.Register(jsonrpc.Methods{
+		"GetUser": func(ctx context.Context) (interface{}, error) {
+			return user{Name: "Alice", Email: "alice@example.com", Age: 30}, nil
+		},
+		"Upper": func(ctx context.Context, s string) (interface{}, error) {
+			return strings.ToUpper(s), nil
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "GetUser", "fields": ["name", "age", "unknown"]}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": {"name": "Alice", "age": 30}}`)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[
+		{"id": 1, "method": "GetUser"},
+		{"id": 2, "method": "Upper", "params": "a"},
+		{"id": 3, "method": "GetUser", "fields": ["age"]},
+		{"id": 4, "method": "GetUser", "fields": "age"}
+	]`))
+	req.Header.Set("X-Fields", "email, name")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.JSONEqual(t, w.Body.String(), `[
+		{"id": 1, "result": {"name": "Alice", "email": "alice@example.com"}},
+		{"id": 2, "result": "A"},
+		{"id": 3, "result": {"age": 30}},
+		{
+			"id": 4,
+			"error": {"name": "invalid_params", "message": "fields must be an array of strings"}
+		}
+	]`)
+
+	server.AllowFieldSelection = false
+	resp = do(server, `{"id": 1, "method": "GetUser", "fields": "name"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"result": {"name": "Alice", "email": "alice@example.com", "age": 30}
+	}`)
+}
+
+func TestAllowBatch(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Upper": func(ctx context.Context, s string) (interface{}, error) {
+			return strings.ToUpper(s), nil
+		},
+	})
+
+	t.Run("AllowBatch=false", func(t *testing.T) {
+		server.AllowBatch = false
+		resp := do(server, `[{"id": 1, "method": "Upper", "params": "a"}]`)
+		assert.Equal(t, resp.Result().StatusCode, 400)
+		assert.JSONEqual(t, resp.Body.String(), `{
+			"error": {"name": "invalid_request", "message": "batch not supported"},
+			"id": null
+		}`)
+
+		resp = do(server, `{"id": 1, "method": "Upper", "params": "a"}`)
+		assert.Equal(t, resp.Result().StatusCode, 200)
+		assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "A"}`)
+	})
+
+	t.Run("AllowBatch=true", func(t *testing.T) {
+		server.AllowBatch = true
+		resp := do(server, `[
+			{"id": 1, "method": "Upper", "params": "a"},
+			{"id": 2, "method": "Upper", "params": "b"}
+		]`)
+		assert.Equal(t, resp.Result().StatusCode, 200)
+		assert.JSONEqual(t, resp.Body.String(), `[
+			{"id": 1, "result": "A"},
+			{"id": 2, "result": "B"}
+		]`)
+	})
+}
+
+func TestMethodInfo(t *testing.T) {
+	type params struct {
+		Name string `json:"name"`
+	}
+	var got []*jsonrpc.MethodInfo
+	server := jsonrpc.New()
+	server.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, p interface{}) (interface{}, error) {
+			got = append(got, jsonrpc.MethodInfoFromContext(ctx))
+			return next(ctx, p)
+		}
+	})
+	server.Register(jsonrpc.Methods{
+		"WithParams": func(ctx context.Context, p params) (interface{}, error) {
+			return nil, nil
+		},
+		"NoParams": func(ctx context.Context) (interface{}, error) {
+			return nil, nil
+		},
+	})
+
+	do(server, `[
+		{"id": 1, "method": "WithParams", "params": {}},
+		{"id": 2, "method": "NoParams"}
+	]`)
+	assert.Equal(t, len(got), 2)
+	assert.Equal(t, got[0].Name, "WithParams")
+	assert.Equal(t, got[0].ParamsType == reflect.TypeOf(params{}), true)
+	assert.Equal(t, got[1].Name, "NoParams")
+	assert.Equal(t, got[1].ParamsType == nil, true)
+	assert.Equal(t, jsonrpc.MethodInfoFromContext(context.Background()), (*jsonrpc.MethodInfo)(nil))
+}
+
+func TestRegisterWithMeta(t *testing.T) {
+	noop := func(context.Context) (interface{}, error) { return nil, nil }
+	var got []jsonrpc.Meta
+	server := jsonrpc.New()
+	server.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			got = append(got, jsonrpc.MethodInfoFromContext(ctx).Meta)
+			return next(ctx, params)
+		}
+	})
+	server.RegisterWithMeta(jsonrpc.Methods{
+		"Login":   noop,
+		"GetUser": noop,
+	}, map[string]jsonrpc.Meta{
+		"Login": {Public: true, RateLimit: 10, Tags: []string{"auth"}},
+	})
+
+	do(server, `[{"id": 1, "method": "Login"}, {"id": 2, "method": "GetUser"}]`)
+	assert.Equal(t, got, []jsonrpc.Meta{
+		{Public: true, RateLimit: 10, Tags: []string{"auth"}},
+		{},
+	})
+
+	var gotPanic interface{}
+	(func() {
+		defer func() { gotPanic = recover() }()
+		server.RegisterWithMeta(jsonrpc.Methods{
+			"Logout": noop,
+		}, map[string]jsonrpc.Meta{
+			"Login": {Public: true},
+		})
+	})()
+	assert.Equal(t, gotPanic, "jsonrpc: meta provided for unknown method: Login")
+}
+
+func TestMaxParamsSize(t *testing.T) {
+	server := jsonrpc.New()
+	server.RegisterWithMeta(jsonrpc.Methods{
+		"Small": func(ctx context.Context, s string) (interface{}, error) {
+			return len(s), nil
+		},
+		"Large": func(ctx context.Context, s string) (interface{}, error) {
+			return len(s), nil
+		},
+	}, map[string]jsonrpc.Meta{
+		"Small": {MaxParamsSize: 10},
+	})
+
+	resp := do(server, `{"id": 1, "method": "Small", "params": "12345678"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": 8}`)
+
+	resp = do(server, `{"id": 1, "method": "Small", "params": "123456789"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {"name": "invalid_params", "message": "params too large"}
+	}`)
+
+	resp = do(server, `{"id": 1, "method": "Large", "params": "123456789"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": 9}`)
+}
+
+func TestNotificationMethods(t *testing.T) {
+	var calls int
+	server := jsonrpc.New()
+	server.RegisterWithMeta(jsonrpc.Methods{
+		"Refresh": func(ctx context.Context) (interface{}, error) {
+			calls++
+			return "ignored", nil
+		},
+		"Ping": func(ctx context.Context) (interface{}, error) {
+			return "pong", nil
+		},
+	}, map[string]jsonrpc.Meta{
+		"Refresh": {Notification: true},
+	})
+
+	resp := do(server, `[
+		{"method": "Refresh"},
+		{"id": 1, "method": "Refresh"},
+		{"method": "Ping"},
+		{"id": 2, "method": "Ping"}
+	]`)
+	assert.Equal(t, calls, 1)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{"id": 1, "error": {"name": "invalid_request", "message": "method Refresh is notification-only"}},
+		{"id": 2, "result": "pong"}
+	]`)
+}
+
+func TestResponseHeaders(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Create": func(ctx context.Context) (interface{}, error) {
+			jsonrpc.SetResponseHeader(ctx, "Location", "/orders/1")
+			return jsonrpc.M{"id": 1}, nil
+		},
+		"Moved": func(ctx context.Context) (interface{}, error) {
+			return nil, jsonrpc.Redirect("/orders/1", http.StatusSeeOther)
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "Create"}`)
+	assert.Equal(t, resp.Result().StatusCode, 200)
+	assert.Equal(t, resp.Header().Get("Location"), "/orders/1")
+
+	resp = do(server, `{"id": 1, "method": "Moved"}`)
+	assert.Equal(t, resp.Result().StatusCode, http.StatusSeeOther)
+	assert.Equal(t, resp.Header().Get("Location"), "/orders/1")
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {
+			"name": "redirect",
+			"message": "see /orders/1",
+			"data": {"location": "/orders/1"}
+		}
+	}`)
+
+	resp = do(server, `[{"id": 1, "method": "Moved"}, {"id": 2, "method": "Moved"}]`)
+	assert.Equal(t, resp.Result().StatusCode, 200)
+	assert.Equal(t, resp.Header().Get("Location"), "")
+
+	// SetResponseHeader is a no-op outside of a request.
+	jsonrpc.SetResponseHeader(context.Background(), "Location", "/")
+}
+
+func TestRetryAfter(t *testing.T) {
+	server := jsonrpc.New()
+	server.ErrorStatusMode = jsonrpc.MapFromName
+	server.Register(jsonrpc.Methods{
+		"Limited": func(ctx context.Context) (interface{}, error) {
+			return nil, jsonrpc.RateLimited(1500 * time.Millisecond)
+		},
+		"Busy": func(ctx context.Context) (interface{}, error) {
+			return nil, jsonrpc.ServiceUnavailable("busy").RetryAfter(time.Minute)
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "Limited"}`)
+	assert.Equal(t, resp.Code, http.StatusTooManyRequests)
+	assert.Equal(t, resp.Header().Get("Retry-After"), "2")
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {
+			"name": "rate_limited",
+			"message": "rate limit exceeded",
+			"retryable": true,
+			"data": {"retry_after": 2}
+		}
+	}`)
+
+	resp = do(server, `{"id": 1, "method": "Busy"}`)
+	assert.Equal(t, resp.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, resp.Header().Get("Retry-After"), "60")
+
+	resp = do(server, `[{"id": 1, "method": "Limited"}, {"id": 2, "method": "Busy"}]`)
+	assert.Equal(t, resp.Code, 200)
+	assert.Equal(t, resp.Header().Get("Retry-After"), "")
+}
+
+func TestSuggestMethods(t *testing.T) {
+	noop := func(context.Context) (interface{}, error) { return nil, nil }
+	server := jsonrpc.New()
+	server.SuggestMethods = true
+	server.Register(jsonrpc.Methods{
+		"GetUser":  noop,
+		"GetUsers": noop,
+		"Login":    noop,
+	})
+
+	resp := do(server, `{"id": 1, "method": "GetUsr"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {
+			"name": "method_not_found",
+			"message": "method not found: GetUsr",
+			"data": {"suggestion": "GetUser"}
+		}
+	}`)
+
+	resp = do(server, `{"id": 1, "method": "DeleteAccount"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {
+			"name": "method_not_found",
+			"message": "method not found: DeleteAccount"
+		}
+	}`)
+}
+
+func TestClientDisconnect(t *testing.T) {
+	var (
+		started   = make(chan struct{})
+		cancelled = make(chan error, 1)
+	)
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Wait": func(ctx context.Context) (interface{}, error) {
+			close(started)
+			<-ctx.Done()
+			cancelled <- ctx.Err()
+			return nil, ctx.Err()
+		},
+	})
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"id": 1, "method": "Wait"}`))
+	assert.Must(t, err)
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, err = http.DefaultClient.Do(req.WithContext(ctx))
+	assert.NotNil(t, err)
+
+	select {
+	case err := <-cancelled:
+		assert.Equal(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("method context was not cancelled")
+	}
+}
+
+func TestOverrideAndUnregister(t *testing.T) {
+	server := jsonrpc.New()
+	g := server.Group()
+	g.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			result, err := next(ctx, params)
+			return fmt.Sprintf("[%v]", result), err
+		}
+	})
+	g.Register(jsonrpc.Methods{
+		"Version": func(ctx context.Context) (interface{}, error) {
+			return "v1", nil
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "Version"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "[v1]"}`)
+
+	// Serve concurrently to exercise locking.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			do(server, `{"id": 1, "method": "Version"}`)
+		}()
+	}
+	server.Override("Version", func(ctx context.Context) (interface{}, error) {
+		return "v2", nil
+	})
+	wg.Wait()
+
+	resp = do(server, `{"id": 1, "method": "Version"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "[v2]"}`)
+
+	server.Unregister("Version")
+	resp = do(server, `{"id": 1, "method": "Version"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {"name": "method_not_found", "message": "method not found: Version"}
+	}`)
+
+	var gotPanic interface{}
+	(func() {
+		defer func() { gotPanic = recover() }()
+		server.Override("Version", func(ctx context.Context) (interface{}, error) {
+			return "v3", nil
+		})
+	})()
+	assert.Equal(t, gotPanic, "jsonrpc: method not registered: Version")
+}
+
+func TestMethodNameNormalizer(t *testing.T) {
+	server := jsonrpc.New()
+	server.MethodNameNormalizer = strings.ToLower
+	server.Register(jsonrpc.Methods{
+		"Hello": func(ctx context.Context) (interface{}, error) {
+			return jsonrpc.MethodInfoFromContext(ctx).Name, nil
+		},
+	})
+
+	for _, name := range []string{"Hello", "hello", "HELLO"} {
+		resp := do(server, `{"id": 1, "method": "`+name+`"}`)
+		assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "Hello"}`)
+	}
+
+	err := server.TryRegister(jsonrpc.Methods{
+		"hello": func(ctx context.Context) (interface{}, error) { return nil, nil },
+	})
+	assert.Equal(t, err.Error(), "jsonrpc: method hello already registered as Hello")
+
+	err = server.TryRegister(jsonrpc.Methods{
+		"Bye": func(ctx context.Context) (interface{}, error) { return nil, nil },
+		"bye": func(ctx context.Context) (interface{}, error) { return nil, nil },
+	})
+	assert.Equal(t, err.Error(), "jsonrpc: methods Bye and bye have the same normalized name")
+
+	server.Override("hello", func(ctx context.Context) (interface{}, error) {
+		return "overridden", nil
+	})
+	resp := do(server, `{"id": 1, "method": "Hello"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "overridden"}`)
+
+	server.Unregister("HELLO")
+	resp = do(server, `{"id": 1, "method": "Hello"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {"name": "method_not_found", "message": "method not found: Hello"}
+	}`)
+}
+
+func TestDurationMeta(t *testing.T) {
+	clock := jsonrpc.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	server := jsonrpc.New()
+	server.Clock = clock
+	server.DurationMeta = true
+	server.Meta = jsonrpc.M{"version": "1"}
+	server.Register(jsonrpc.Methods{
+		"Sleep": func(ctx context.Context, ms int) (interface{}, error) {
+			clock.Advance(time.Duration(ms) * time.Millisecond)
+			return nil, nil
+		},
+	})
+
+	resp := do(server, `[
+		{"id": 1, "method": "Sleep", "params": 5},
+		{"id": 2, "method": "Sleep", "params": 1500}
+	]`)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{"id": 1, "meta": {"version": "1", "duration_ms": 5}},
+		{"id": 2, "meta": {"version": "1", "duration_ms": 1500}}
+	]`)
+}
+
+func TestServerTiming(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Do": func(ctx context.Context) (interface{}, error) {
+			return nil, nil
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "Do"}`)
+	assert.Equal(t, resp.Header().Get("Server-Timing"), "")
+
+	server.ServerTiming = true
+	resp = do(server, `{"id": 1, "method": "Do"}`)
+	timing := resp.Header().Get("Server-Timing")
+	var parse, dispatch, encode float64
+	_, err := fmt.Sscanf(timing, "parse;dur=%f, dispatch;dur=%f, encode;dur=%f", &parse, &dispatch, &encode)
+	assert.Must(t, err)
+}
+
+func TestPreventDupeMethods(t *testing.T) {
+	noop := func(context.Context) (interface{}, error) { return nil, nil }
+	h := jsonrpc.New()
+	h.Register(jsonrpc.Methods{
+		"Do": noop,
+	})
+
+	var gotPanic interface{}
+	(func() {
+		defer func() { gotPanic = recover() }()
+		h.Group().Register(jsonrpc.Methods{
+			"Do": noop,
+		})
+	})()
+	assert.Equal(t, gotPanic, "jsonrpc: method already registered: Do")
+}
+
+func ping(context.Context) (interface{}, error) { return "pong", nil }
+
+func pingV2(context.Context) (interface{}, error) { return "pong", nil }
+
+func TestRegisterAll(t *testing.T) {
+	h := jsonrpc.New()
+	h.RegisterAll(
+		jsonrpc.Methods{"Ping": ping},
+		jsonrpc.Methods{"Other": pingV2},
+	)
+	resp := do(h, `[{"id": 1, "method": "Ping"}, {"id": 2, "method": "Other"}]`)
+	assert.JSONEqual(t, resp.Body.String(), `[{"id": 1, "result": "pong"}, {"id": 2, "result": "pong"}]`)
+
+	var gotPanic interface{}
+	(func() {
+		defer func() { gotPanic = recover() }()
+		h.Group().RegisterAll(
+			jsonrpc.Methods{"Dupe": ping},
+			jsonrpc.Methods{"Unique": ping},
+			jsonrpc.Methods{"Dupe": pingV2},
+		)
+	})()
+	assert.Equal(t, gotPanic, "jsonrpc: method Dupe defined twice: "+
+		"by github.com/deliveroo/jsonrpc-go_test.ping (maps[0]) and "+
+		"github.com/deliveroo/jsonrpc-go_test.pingV2 (maps[2])")
+
+	// Nothing is registered if any method is defined twice.
+	resp = do(h, `{"id": 1, "method": "Unique"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {"name": "method_not_found", "message": "method not found: Unique"}
+	}`)
+}
+
+func TestContextlessMethods(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Version": func() (interface{}, error) {
+			return "1.0", nil
+		},
+		"Double": func(n int) (interface{}, error) {
+			return n * 2, nil
+		},
+	})
+
+	resp := do(server, `[
+		{"id": 1, "method": "Version"},
+		{"id": 2, "method": "Double", "params": 21},
+		{"id": 3, "method": "Double", "params": "x"}
+	]`)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{"id": 1, "result": "1.0"},
+		{"id": 2, "result": 42},
+		{"id": 3, "error": {"name": "parse_error", "message": "cannot parse params: offset 3: cannot unmarshal string as integer"}}
+	]`)
+}
+
+func TestTryRegister(t *testing.T) {
+	noop := func(context.Context) (interface{}, error) { return nil, nil }
+	h := jsonrpc.New()
+	assert.Equal(t, h.TryRegister(jsonrpc.Methods{"Do": noop}), nil)
+
+	err := h.TryRegister(jsonrpc.Methods{"Do": noop})
+	assert.Equal(t, err.Error(), "jsonrpc: method already registered: Do")
+
+	err = h.TryRegister(jsonrpc.Methods{
+		"Other": noop,
+		"Bad":   func() {},
+	})
+	assert.Equal(t, err.Error(), "invalid signature: "+
+		"want func(ctx context.Context, params T) (interface{}, error) or "+
+		"func(ctx context.Context) (interface{}, error), got func()")
+
+	// Nothing is registered if any method is invalid.
+	resp := do(h, `{"id": 1, "method": "Other"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {"name": "method_not_found", "message": "method not found: Other"}
+	}`)
+
+	err = h.TryRegister(jsonrpc.Methods{"NotFunc": 1})
+	assert.Equal(t, err.Error(), "int is not a function")
+}
+
+func TestPreventMiddlewareAfterRegister(t *testing.T) {
+	noop := func(context.Context) (interface{}, error) { return nil, nil }
+	h := jsonrpc.New()
+	h.Register(jsonrpc.Methods{
+		"Do": noop,
+	})
+
+	var gotPanic interface{}
+	(func() {
+		defer func() { gotPanic = recover() }()
+		h.Use(func(next jsonrpc.Next) jsonrpc.Next {
+			return func(ctx context.Context, params interface{}) (interface{}, error) {
+				return next(ctx, params)
+			}
+		})
+	})()
+
+	assert.Equal(t, gotPanic, "jsonrpc: middleware must be registered before methods")
+}
+
+func TestUseFor(t *testing.T) {
+	var calls []string
+	tag := func(label string) jsonrpc.Middleware {
+		return func(next jsonrpc.Next) jsonrpc.Next {
+			return func(ctx context.Context, params interface{}) (interface{}, error) {
+				calls = append(calls, label)
+				return next(ctx, params)
+			}
+		}
+	}
+	noop := func(context.Context) (interface{}, error) { return nil, nil }
+	server := jsonrpc.New()
+	server.Use(tag("all"))
+	server.UseFor("admin.*", tag("admin"))
+	server.UseFor("*.Delete", tag("delete"))
+	server.Register(jsonrpc.Methods{
+		"admin.Delete": noop,
+		"admin.List":   noop,
+		"user.Delete":  noop,
+		"user.List":    noop,
+	})
+
+	tests := []struct {
+		method string
+		calls  []string
+	}{
+		{"admin.Delete", []string{"all", "admin", "delete"}},
+		{"admin.List", []string{"all", "admin"}},
+		{"user.Delete", []string{"all", "delete"}},
+		{"user.List", []string{"all"}},
+	}
+	for _, tt := range tests {
+		calls = nil
+		do(server, fmt.Sprintf(`{"id": 1, "method": %q}`, tt.method))
+		assert.Equal(t, calls, tt.calls)
+	}
+
+	var gotPanic interface{}
+	(func() {
+		defer func() { gotPanic = recover() }()
+		server.Group().UseFor("admin.[", tag("invalid"))
+	})()
+	assert.Equal(t, gotPanic, `jsonrpc: invalid pattern "admin.[": syntax error in pattern`)
+}
+
+func TestMiddlewareAfterRegisterInOtherGroup(t *testing.T) {
+	noop := func(context.Context) (interface{}, error) { return "ok", nil }
+	h := jsonrpc.New()
+	a, b := h.Group(), h.Group()
+	sub := b.Group()
+	a.Register(jsonrpc.Methods{
+		"A": noop,
+	})
+
+	// Group b and its subgroups haven't registered methods yet.
+	b.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			return "b", nil
+		}
+	})
+	sub.Register(jsonrpc.Methods{
+		"B": noop,
+	})
+	assert.JSONEqual(t, do(h, `{"id": 1, "method": "A"}`).Body.String(), `{"id": 1, "result": "ok"}`)
+	assert.JSONEqual(t, do(h, `{"id": 1, "method": "B"}`).Body.String(), `{"id": 1, "result": "b"}`)
+
+	// But b's subgroup has now.
+	var gotPanic interface{}
+	(func() {
+		defer func() { gotPanic = recover() }()
+		b.Use(func(next jsonrpc.Next) jsonrpc.Next { return next })
+	})()
 	assert.Equal(t, gotPanic, "jsonrpc: middleware must be registered before methods")
 }
 
@@ -0,0 +1,58 @@
+// Package jsonrpctest provides utilities for testing servers built with the
+// jsonrpc package.
+package jsonrpctest
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// AssertNoLeaks records the running goroutines, returning a function that
+// fails t if any others are still running once they have had a second to
+// exit, such as those of methods abandoned by cancelled or timed-out
+// requests. It is used as:
+//
+//	defer jsonrpctest.AssertNoLeaks(t)()
+//
+// Goroutines started by other tests running in parallel are reported too, so
+// it should only be used by tests that are not run in parallel.
+func AssertNoLeaks(t testing.TB) func() {
+	before := goroutines()
+	return func() {
+		t.Helper()
+		var leaked []string
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+			leaked = leaked[:0]
+			for id, stack := range goroutines() {
+				if _, ok := before[id]; !ok {
+					leaked = append(leaked, stack)
+				}
+			}
+			if len(leaked) == 0 {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Errorf("leaked %d goroutines:\n\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+	}
+}
+
+// goroutines returns the stacks of the running goroutines, other than the
+// caller and those of the test runner, keyed by their ids.
+func goroutines() map[string]string {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	result := make(map[string]string)
+	for i, stack := range strings.Split(string(buf), "\n\n") {
+		if i == 0 || strings.Contains(stack, "testing.(*T).Run") ||
+			strings.Contains(stack, "testing.tRunner.func") ||
+			strings.Contains(stack, "testing.runTests") {
+			continue // the caller, or part of the test runner
+		}
+		id := strings.Join(strings.Fields(stack)[:2], " ") // "goroutine N"
+		result[id] = stack
+	}
+	return result
+}
@@ -0,0 +1,37 @@
+package jsonrpctest_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go/jsonrpctest"
+)
+
+// recorder is a testing.TB that records errors rather than failing.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertNoLeaks(t *testing.T) {
+	rec := &recorder{TB: t}
+	done := make(chan struct{})
+	check := jsonrpctest.AssertNoLeaks(rec)
+	go func() { <-done }()
+	check()
+	close(done)
+	assert.Equal(t, len(rec.errors), 1)
+
+	rec = &recorder{TB: t}
+	check = jsonrpctest.AssertNoLeaks(rec)
+	exited := make(chan struct{})
+	go func() { close(exited) }()
+	<-exited
+	check()
+	assert.Equal(t, len(rec.errors), 0)
+}
@@ -0,0 +1,37 @@
+package jsonrpc
+
+import (
+	"strconv"
+	"strings"
+)
+
+// preferredLanguage returns the language tag with the highest quality value
+// from an Accept-Language header, or "" if there is none. Ties are broken by
+// the order in which tags appear.
+func preferredLanguage(header string) string {
+	var (
+		best  string
+		bestQ = -1.0
+	)
+	for _, part := range strings.Split(header, ",") {
+		tag, q := strings.TrimSpace(part), 1.0
+		if i := strings.Index(tag, ";"); i != -1 {
+			param := strings.TrimSpace(tag[i+1:])
+			tag = strings.TrimSpace(tag[:i])
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					continue
+				}
+				q = v
+			}
+		}
+		if tag == "" || tag == "*" || q <= 0 {
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,23 @@
+package jsonrpc
+
+import "testing"
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"fr", "fr"},
+		{"fr-CH, fr;q=0.9, en;q=0.8", "fr-CH"},
+		{"en;q=0.5, de;q=0.8", "de"},
+		{"*, it;q=0.1", "it"},
+		{"en;q=0, es", "es"},
+		{"en;q=abc", ""},
+	}
+	for _, tt := range tests {
+		if got := preferredLanguage(tt.header); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
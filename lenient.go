@@ -0,0 +1,140 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// isStringForNumber reports whether err is the result of unmarshaling a JSON
+// string into a numeric value.
+func isStringForNumber(err error) bool {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Value != "string" {
+		return false
+	}
+	switch jsonType(typeErr.Type) {
+	case "integer", "number":
+		return true
+	}
+	return false
+}
+
+// coerceNumbers returns raw with any strings that would be unmarshaled into
+// numeric values of type t replaced by the numbers they contain, e.g.
+// {"count": "5"} becomes {"count": 5} if count is an int. It reports false if
+// nothing was replaced.
+func coerceNumbers(raw json.RawMessage, t reflect.Type) (json.RawMessage, bool) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	v, changed := coerceValue(v, t)
+	if !changed {
+		return nil, false
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+var typeJSONUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// coerceValue implements coerceNumbers for a decoded JSON value v.
+func coerceValue(v interface{}, t reflect.Type) (interface{}, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(typeJSONUnmarshaler) {
+		return v, false // custom decoding
+	}
+	switch v := v.(type) {
+	case string:
+		switch jsonType(t) {
+		case "integer", "number":
+			n := json.Number(strings.TrimSpace(v))
+			if _, err := n.Float64(); err == nil && json.Valid([]byte(n)) {
+				return n, true
+			}
+		}
+	case map[string]interface{}:
+		changed := false
+		for key, elem := range v {
+			var elemType reflect.Type
+			switch t.Kind() {
+			case reflect.Struct:
+				elemType = fieldType(t, key)
+			case reflect.Map:
+				elemType = t.Elem()
+			}
+			if elemType == nil {
+				continue
+			}
+			if coerced, ok := coerceValue(elem, elemType); ok {
+				v[key] = coerced
+				changed = true
+			}
+		}
+		return v, changed
+	case []interface{}:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			break
+		}
+		changed := false
+		for i, elem := range v {
+			if coerced, ok := coerceValue(elem, t.Elem()); ok {
+				v[i] = coerced
+				changed = true
+			}
+		}
+		return v, changed
+	}
+	return v, false
+}
+
+// fieldType returns the type of the field of struct type t that a JSON object
+// member with the given key is unmarshaled into, or nil if there is none.
+// Like encoding/json, it prefers an exact match of the field's JSON name, but
+// accepts a case-insensitive one.
+func fieldType(t reflect.Type, key string) reflect.Type {
+	var fold reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if et := fieldType(ft, key); et != nil {
+					return et
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if name == key {
+			return f.Type
+		}
+		if fold == nil && strings.EqualFold(name, key) {
+			fold = f.Type
+		}
+	}
+	return fold
+}
@@ -0,0 +1,65 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestLenientNumbers(t *testing.T) {
+	type Paging struct {
+		Limit int `json:"limit"`
+	}
+	type params struct {
+		Paging
+		Count  int            `json:"count"`
+		Price  *float64       `json:"price"`
+		Code   string         `json:"code"`
+		IDs    []int64        `json:"ids"`
+		Totals map[string]int `json:"totals"`
+	}
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Echo": func(ctx context.Context, p params) (interface{}, error) {
+			return p, nil
+		},
+	})
+	body := `{"id": 1, "method": "Echo", "params": {
+		"Limit": "10",
+		"count": " 5",
+		"price": "1.5",
+		"code": "007",
+		"ids": ["1", 2],
+		"totals": {"a": "3"}
+	}}`
+
+	errorName := func(resp *httptest.ResponseRecorder) string {
+		var r struct{ Error *jsonrpc.RPCError }
+		assert.Must(t, json.Unmarshal(resp.Body.Bytes(), &r))
+		if r.Error == nil {
+			return ""
+		}
+		return r.Error.Name
+	}
+
+	resp := do(server, body)
+	assert.Equal(t, errorName(resp), "parse_error")
+
+	server.LenientNumbers = true
+	resp = do(server, body)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": {
+		"limit": 10,
+		"count": 5,
+		"price": 1.5,
+		"code": "007",
+		"ids": [1, 2],
+		"totals": {"a": 3}
+	}}`)
+
+	resp = do(server, `{"id": 1, "method": "Echo", "params": {"count": "five"}}`)
+	assert.Equal(t, errorName(resp), "parse_error")
+}
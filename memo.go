@@ -0,0 +1,73 @@
+package jsonrpc
+
+import (
+	"context"
+	"sync"
+)
+
+// Once returns the result of loader for key, calling loader at most once per
+// RPC request. Later calls with the same key, e.g. from a method after its
+// middleware, return the memoized result and error, waiting for the first
+// call to complete if necessary. If loader panics, later calls panic with the
+// same value. Each request in a batch has its own values.
+//
+// Keys should be of an unexported type to avoid collisions, as with
+// context.WithValue. If ctx did not originate from a Handler, loader is
+// called every time.
+func Once(ctx context.Context, key interface{}, loader func() (interface{}, error)) (interface{}, error) {
+	s := stateFromContext(ctx)
+	if s == nil {
+		return loader()
+	}
+	s.mu.Lock()
+	if s.memo == nil {
+		s.memo = make(map[interface{}]*call)
+	}
+	c, ok := s.memo[key]
+	if !ok {
+		c = &call{}
+		c.wg.Add(1)
+		s.memo[key] = c
+	}
+	s.mu.Unlock()
+
+	if ok {
+		c.wg.Wait()
+		return c.get()
+	}
+	c.run(loader)
+	c.wg.Done()
+	return c.get()
+}
+
+// call is a memoized call to a loader, which later calls wait for.
+type call struct {
+	wg       sync.WaitGroup
+	result   interface{}
+	err      error
+	panicked bool        // fn panicked with panicVal
+	panicVal interface{} // re-panicked to every caller
+}
+
+// run calls fn, recording its result and error, or the value it panicked
+// with, for get.
+func (c *call) run(fn func() (interface{}, error)) {
+	normal := false
+	defer func() {
+		if !normal {
+			c.panicked = true
+			c.panicVal = recover()
+		}
+	}()
+	c.result, c.err = fn()
+	normal = true
+}
+
+// get returns the outcome recorded by run, panicking again if fn panicked so
+// that each caller fails as the first one did.
+func (c *call) get() (interface{}, error) {
+	if c.panicked {
+		panic(c.panicVal)
+	}
+	return c.result, c.err
+}
@@ -0,0 +1,78 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestOnce(t *testing.T) {
+	type userKey struct{}
+	var loads int
+	loadUser := func(ctx context.Context) (interface{}, error) {
+		return jsonrpc.Once(ctx, userKey{}, func() (interface{}, error) {
+			loads++
+			return "alice", nil
+		})
+	}
+
+	server := jsonrpc.New()
+	server.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			if _, err := loadUser(ctx); err != nil {
+				return nil, err
+			}
+			return next(ctx, params)
+		}
+	})
+	server.Register(jsonrpc.Methods{
+		"Whoami": func(ctx context.Context) (interface{}, error) {
+			return loadUser(ctx)
+		},
+	})
+
+	resp := do(server, `[{"id": 1, "method": "Whoami"}, {"id": 2, "method": "Whoami"}]`)
+	assert.JSONEqual(t, resp.Body.String(), `[{"id": 1, "result": "alice"}, {"id": 2, "result": "alice"}]`)
+	assert.Equal(t, loads, 2) // once per request in the batch
+
+	loads = 0
+	_, _ = loadUser(context.Background())
+	_, _ = loadUser(context.Background())
+	assert.Equal(t, loads, 2)
+}
+
+func TestOncePanic(t *testing.T) {
+	type userKey struct{}
+	var loads int
+	loadUser := func(ctx context.Context) (interface{}, error) {
+		return jsonrpc.Once(ctx, userKey{}, func() (interface{}, error) {
+			loads++
+			panic("boom")
+		})
+	}
+
+	server := jsonrpc.New()
+	server.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			func() {
+				defer func() { _ = recover() }()
+				_, _ = loadUser(ctx)
+			}()
+			return next(ctx, params)
+		}
+	})
+	server.Register(jsonrpc.Methods{
+		"Whoami": func(ctx context.Context) (interface{}, error) {
+			return loadUser(ctx)
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "Whoami"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {"name": "internal_error", "message": "internal error", "retryable": true}
+	}`)
+	assert.Equal(t, loads, 1)
+}
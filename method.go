@@ -2,6 +2,7 @@ package jsonrpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 )
@@ -13,12 +14,91 @@ import (
 //     func(ctx context.Context, params T) (interface{}, error) // JSON unmarshable params
 //     func(ctx context.Context) (interface{}, error)           // no params
 //
+// Streaming methods additionally accept an Emit function as their last
+// argument:
+//
+//     func(ctx context.Context, params T, emit Emit) (interface{}, error)
+//     func(ctx context.Context, emit Emit) (interface{}, error)
+//
+// Methods that don't use their context may omit it:
+//
+//     func(params T) (interface{}, error)
+//     func() (interface{}, error)
+//
+// A method may also return a channel, such as a <-chan interface{}, as its
+// result. The response is then sent with chunked encoding, and each value
+// received from the channel is rendered as an element of the result array
+// as soon as it arrives, until the channel is closed. The method should
+// stop sending when its context is done, since values are no longer received
+// once the client has gone away. Like streaming methods, such methods cannot
+// be called as part of a batch.
+//
 type MethodFunc interface{}
 
+// Emit sends a notification to the client from a streaming method. Each call
+// writes a JSON-RPC notification object, {"method": ..., "params": v}, on its
+// own line and flushes it to the client. The method's result is written last.
+// If Handler.IDGenerator is set, each notification also has an "id".
+//
+// Streaming methods cannot be called as part of a batch.
+type Emit func(v interface{}) error
+
+// MethodInfo describes a registered RPC method. It is available to middleware
+// via MethodInfoFromContext.
+type MethodInfo struct {
+	// Name is the name the method was registered under.
+	Name string
+
+	// ParamsType is the type of the params accepted by the method, or nil if
+	// the method does not accept params.
+	ParamsType reflect.Type
+
+	// Meta is the metadata provided when the method was registered.
+	Meta Meta
+}
+
+// Meta holds per-method policy provided at registration time with
+// RegisterWithMeta. Other than Notification and MaxParamsSize, it is not
+// interpreted by the handler itself, but may be used by middleware.
+type Meta struct {
+	// Cacheable indicates that results of the method may be cached.
+	Cacheable bool
+
+	// Public indicates that the method may be called without authentication.
+	Public bool
+
+	// RateLimit is the maximum number of calls allowed per unit of time, as
+	// interpreted by rate limiting middleware. Zero means no limit.
+	RateLimit int
+
+	// Tags holds arbitrary labels for the method.
+	Tags []string
+
+	// Notification indicates that the method has no meaningful result, and
+	// must be called as a notification, without an id. Calls with an id fail
+	// with an invalid_request error. Outside of a batch, the response to a
+	// notification has a null id and result; within a batch, notifications are
+	// not answered.
+	Notification bool
+
+	// MaxParamsSize is the maximum size of the method's params, in bytes of
+	// JSON. Calls with larger params fail with an invalid_params error,
+	// before the params are unmarshaled. Zero means no limit.
+	MaxParamsSize int
+}
+
 type method struct {
 	Name       string
 	fn         reflect.Value
 	paramsType reflect.Type
+	info       *MethodInfo
+	group      *Group       // group the method was registered with
+	stream     bool         // accepts an Emit argument
+	hasCtx     bool         // accepts a context.Context argument
+	depth      int          // number of middleware wrapping the method
+	seq        int          // registration order, starting at 1
+	cache      *cachePolicy // set by RegisterCached
+	mount      *string      // prefix the method was mounted under, if any
 
 	call func(context.Context, interface{}) (interface{}, error)
 }
@@ -27,56 +107,111 @@ var (
 	typeContextContext = reflect.TypeOf((*context.Context)(nil)).Elem()
 	typeEmptyInterface = reflect.TypeOf((*interface{})(nil)).Elem()
 	typeError          = reflect.TypeOf((*error)(nil)).Elem()
+	typeEmit           = reflect.TypeOf((*Emit)(nil)).Elem()
 )
 
-func (g *Group) resolveMethod(name string, fn MethodFunc) method {
+func (g *Group) resolveMethod(name string, fn MethodFunc, meta Meta, cache *cachePolicy) (method, error) {
 	val := reflect.ValueOf(fn)
 	if val.Kind() != reflect.Func {
-		panic(val.Type().String() + " is not a function")
+		return method{}, errors.New(val.Type().String() + " is not a function")
 	}
 
 	// Validate signature.
 	t := val.Type()
-	valid := (t.NumIn() == 1 || t.NumIn() == 2) &&
-		t.In(0) == typeContextContext &&
+	numIn := t.NumIn()
+	stream := numIn > 0 && t.In(numIn-1) == typeEmit
+	if stream {
+		numIn--
+	}
+	hasCtx := numIn > 0 && t.In(0) == typeContextContext
+	numParams := numIn
+	if hasCtx {
+		numParams--
+	}
+	valid := (numParams == 0 || numParams == 1) &&
 		t.NumOut() == 2 &&
 		t.Out(0) == typeEmptyInterface &&
 		t.Out(1) == typeError
 	if !valid {
-		panic(fmt.Sprintf("invalid signature: "+
+		return method{}, fmt.Errorf("invalid signature: "+
 			"want func(ctx context.Context, params T) (interface{}, error) or "+
 			"func(ctx context.Context) (interface{}, error), "+
-			"got %v", val.Type()))
+			"got %v", val.Type())
 	}
 	m := method{
-		Name: name,
-		fn:   val,
+		Name:   name,
+		fn:     val,
+		group:  g,
+		stream: stream,
+		hasCtx: hasCtx,
+		cache:  cache,
+	}
+	if numParams == 1 {
+		m.paramsType = t.In(numIn - 1)
 	}
-	if t.NumIn() == 2 {
-		m.paramsType = t.In(1)
+	m.info = &MethodInfo{
+		Name:       name,
+		ParamsType: m.paramsType,
+		Meta:       meta,
 	}
 
 	m.call = func(ctx context.Context, params interface{}) (interface{}, error) {
-		args := append(make([]reflect.Value, 0, 2),
-			reflect.ValueOf(ctx),
-		)
+		args := make([]reflect.Value, 0, 3)
+		if m.hasCtx {
+			args = append(args,
+				reflect.ValueOf(ctx),
+			)
+		}
 		if m.paramsType != nil {
 			args = append(args,
 				reflect.ValueOf(params),
 			)
 		}
+		if m.stream {
+			emit := stateFromContext(ctx).emit
+			if emit == nil {
+				return nil, InvalidRequest("method %s cannot be called in a batch", m.Name)
+			}
+			args = append(args,
+				reflect.ValueOf(emit),
+			)
+		}
 		outs := m.fn.Call(args)
 		result, errVal := outs[0].Interface(), outs[1].Interface()
 		err, _ := errVal.(error)
 		return result, err
 	}
 
+	// Serve results from the cache, inside of all middleware.
+	if cache != nil {
+		m.call = g.server.wrapCache(m.call, name, cache)
+	}
+
+	// Skip the method in dry runs, inside of all middleware, so that they
+	// still authenticate and validate the request.
+	m.call = wrapDryRun(m.call)
+
+	// Render results, inside of all middleware, so that Render sees the
+	// values they add to the context.
+	if !meta.Notification {
+		m.call = wrapRender(m.call)
+	}
+
+	// Apply error mappers, inside of all middleware.
+	for g := g; g != nil; g = g.parent {
+		if g.errorMapper != nil {
+			m.call = wrapErrorMapper(m.call, g.errorMapper)
+		}
+	}
+
 	// Apply middleware.
-	cnt := 0
+	leaf := g
 	for {
 		for i := len(g.middleware) - 1; i >= 0; i-- {
-			m.call = g.middleware[i](m.call)
-			cnt++
+			if gm := g.middleware[i]; gm.appliesTo(name) {
+				m.call = gm.mw(m.call)
+				m.depth++
+			}
 		}
 		if g.parent == nil {
 			break
@@ -84,7 +219,38 @@ func (g *Group) resolveMethod(name string, fn MethodFunc) method {
 		g = g.parent
 	}
 
-	return m
+	// Apply error handlers, outside of all middleware.
+	for g := leaf; g != nil; g = g.parent {
+		for i := len(g.errorHandlers) - 1; i >= 0; i-- {
+			m.call = wrapErrorHandler(m.call, g.errorHandlers[i])
+		}
+	}
+
+	return m, nil
+}
+
+// wrapErrorMapper returns a Next that passes any error returned by next
+// through fn.
+func wrapErrorMapper(next Next, fn func(error) error) Next {
+	return func(ctx context.Context, params interface{}) (interface{}, error) {
+		result, err := next(ctx, params)
+		if err != nil {
+			return nil, fn(err)
+		}
+		return result, nil
+	}
+}
+
+// wrapErrorHandler returns a Next that passes any error returned by next
+// through fn.
+func wrapErrorHandler(next Next, fn ErrorHandler) Next {
+	return func(ctx context.Context, params interface{}) (interface{}, error) {
+		result, err := next(ctx, params)
+		if err != nil {
+			return nil, fn(ctx, err)
+		}
+		return result, nil
+	}
 }
 
 // newParams allocates a new instance of the params expected by this RPC Method.
@@ -0,0 +1,52 @@
+package jsonrpc
+
+import (
+	"context"
+	"time"
+)
+
+// MetricsRecorder records metrics for MetricsMiddleware, so that they may be
+// exported to a monitoring system. The Middleware of the
+// github.com/deliveroo/jsonrpc-go/prometheus module records them with
+// Prometheus.
+type MetricsRecorder interface {
+	// InFlight adds delta, which is 1 or -1, to the number of method calls
+	// in flight.
+	InFlight(delta int)
+
+	// Observe records a completed call to method, which took d. errName is
+	// the name of the error returned, or "" if the call succeeded.
+	Observe(method, errName string, d time.Duration)
+}
+
+// MetricsMiddleware returns middleware that records the number of method
+// calls in flight, and the outcome and duration of each call, with rec. Errors
+// that are not RPCErrors, and panics, are recorded as "internal_error".
+// Durations are measured with ClockFromContext. Placed first, it also measures
+// the time spent in other middleware.
+func MetricsMiddleware(rec MetricsRecorder) Middleware {
+	return func(next Next) Next {
+		return func(ctx context.Context, params interface{}) (result interface{}, err error) {
+			clock := ClockFromContext(ctx)
+			start := clock.Now()
+			rec.InFlight(1)
+			defer rec.InFlight(-1)
+
+			// Record the call even if the method panics, leaving the panic
+			// to propagate.
+			normal := false
+			defer func() {
+				var errName string
+				if !normal {
+					errName = "internal_error"
+				} else if err != nil {
+					errName = translateError(err).Name
+				}
+				rec.Observe(MethodFromContext(ctx), errName, clock.Now().Sub(start))
+			}()
+			result, err = next(ctx, params)
+			normal = true
+			return result, err
+		}
+	}
+}
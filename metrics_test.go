@@ -0,0 +1,74 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+type observation struct {
+	Method, ErrName string
+	D               time.Duration
+	InFlight        int
+}
+
+type testRecorder struct {
+	mu           sync.Mutex
+	inFlight     int
+	observations []observation
+}
+
+func (r *testRecorder) InFlight(delta int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inFlight += delta
+}
+
+func (r *testRecorder) Observe(method, errName string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observations = append(r.observations, observation{method, errName, d, r.inFlight})
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	clock := jsonrpc.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	rec := &testRecorder{}
+	server := jsonrpc.New()
+	server.Clock = clock
+	server.Use(jsonrpc.MetricsMiddleware(rec))
+	server.Register(jsonrpc.Methods{
+		"Slow": func(ctx context.Context) (interface{}, error) {
+			clock.Advance(250 * time.Millisecond)
+			return "done", nil
+		},
+		"NotFound": func(ctx context.Context) (interface{}, error) {
+			return nil, jsonrpc.NotFound("not found")
+		},
+		"Broken": func(ctx context.Context) (interface{}, error) {
+			return nil, errors.New("broken")
+		},
+		"Panics": func(ctx context.Context) (interface{}, error) {
+			clock.Advance(time.Second)
+			panic("boom")
+		},
+	})
+
+	do(server, `[
+		{"id": 1, "method": "Slow"},
+		{"id": 2, "method": "NotFound"},
+		{"id": 3, "method": "Broken"},
+		{"id": 4, "method": "Panics"}
+	]`)
+	assert.Equal(t, rec.observations, []observation{
+		{"Slow", "", 250 * time.Millisecond, 1},
+		{"NotFound", "not_found", 0, 1},
+		{"Broken", "internal_error", 0, 1},
+		{"Panics", "internal_error", time.Second, 1},
+	})
+	assert.Equal(t, rec.inFlight, 0)
+}
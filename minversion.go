@@ -0,0 +1,97 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MinVersionMiddleware returns middleware that rejects requests from clients
+// older than min, a semantic version such as "2.1.0", with an
+// upgrade_required error, which has status 426 with the MapFromName
+// ErrorStatusMode. The client's version is read from the given header of the
+// HTTP request, and requests with a missing version are also rejected, while
+// those with an invalid version are rejected with an invalid_request error.
+// Requests received over other transports, which have no headers, are always
+// accepted.
+//
+// Versions may have a leading "v", and omit their minor and patch numbers.
+// Pre-release versions, such as "2.1.0-beta", precede the release itself,
+// and build metadata is ignored. MinVersionMiddleware panics if min is not a
+// valid version.
+func MinVersionMiddleware(header string, min string) Middleware {
+	minVersion, err := parseVersion(min)
+	if err != nil {
+		panic("jsonrpc: " + err.Error())
+	}
+	return func(next Next) Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			r := RequestFromContext(ctx)
+			if r == nil {
+				return next(ctx, params)
+			}
+			s := r.Header.Get(header)
+			if s == "" {
+				return nil, UpgradeRequired("client version required")
+			}
+			v, err := parseVersion(s)
+			if err != nil {
+				return nil, InvalidRequest("invalid %s header: %s", header, err)
+			}
+			if v.less(minVersion) {
+				return nil, UpgradeRequired("client version %s is no longer supported, upgrade to %s or later", s, min)
+			}
+			return next(ctx, params)
+		}
+	}
+}
+
+// version is a parsed semantic version.
+type version struct {
+	nums [3]uint64 // major, minor and patch
+	pre  string    // pre-release
+}
+
+// parseVersion parses s as a semantic version.
+func parseVersion(s string) (version, error) {
+	var v version
+	rest := strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		rest = rest[:i]
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		rest, v.pre = rest[:i], rest[i+1:]
+	}
+	parts := strings.Split(rest, ".")
+	if len(parts) > len(v.nums) {
+		return version{}, fmt.Errorf("invalid version: %q", s)
+	}
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return version{}, fmt.Errorf("invalid version: %q", s)
+		}
+		v.nums[i] = n
+	}
+	return v, nil
+}
+
+// less reports whether v precedes w. Pre-releases are compared as strings,
+// rather than by their dot-separated identifiers.
+func (v version) less(w version) bool {
+	for i := range v.nums {
+		if v.nums[i] != w.nums[i] {
+			return v.nums[i] < w.nums[i]
+		}
+	}
+	switch {
+	case v.pre == w.pre:
+		return false
+	case v.pre == "":
+		return false
+	case w.pre == "":
+		return true
+	}
+	return v.pre < w.pre
+}
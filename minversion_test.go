@@ -0,0 +1,63 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestMinVersionMiddleware(t *testing.T) {
+	server := jsonrpc.New()
+	server.ErrorStatusMode = jsonrpc.MapFromName
+	server.Use(jsonrpc.MinVersionMiddleware("X-Client-Version", "2.1.0"))
+	server.Register(jsonrpc.Methods{
+		"Ping": func(ctx context.Context) (interface{}, error) {
+			return "pong", nil
+		},
+	})
+
+	tests := []struct {
+		version string
+		status  int
+		resp    string
+	}{
+		{"2.1.0", 200, `{"id": 1, "result": "pong"}`},
+		{"v2.1", 200, `{"id": 1, "result": "pong"}`},
+		{"2.10.0+build.5", 200, `{"id": 1, "result": "pong"}`},
+		{"3", 200, `{"id": 1, "result": "pong"}`},
+		{"2.0.9", 426, `{"id": 1, "error": {"name": "upgrade_required", "message": "client version 2.0.9 is no longer supported, upgrade to 2.1.0 or later"}}`},
+		{"2.1.0-beta", 426, `{"id": 1, "error": {"name": "upgrade_required", "message": "client version 2.1.0-beta is no longer supported, upgrade to 2.1.0 or later"}}`},
+		{"", 426, `{"id": 1, "error": {"name": "upgrade_required", "message": "client version required"}}`},
+		{"latest", 400, `{"id": 1, "error": {"name": "invalid_request", "message": "invalid X-Client-Version header: invalid version: \"latest\""}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id": 1, "method": "Ping"}`))
+			if tt.version != "" {
+				req.Header.Set("X-Client-Version", tt.version)
+			}
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, resp.Result().StatusCode, tt.status)
+			assert.JSONEqual(t, resp.Body.String(), tt.resp)
+		})
+	}
+
+	t.Run("dispatch", func(t *testing.T) {
+		b, err := server.Dispatch(context.Background(), []byte(`{"id": 1, "method": "Ping"}`))
+		assert.Must(t, err)
+		assert.JSONEqual(t, string(b), `{"id": 1, "result": "pong"}`)
+	})
+}
+
+func TestMinVersionMiddlewareInvalid(t *testing.T) {
+	defer func() {
+		assert.Equal(t, recover(), `jsonrpc: invalid version: "2.x"`)
+	}()
+	jsonrpc.MinVersionMiddleware("X-Client-Version", "2.x")
+}
@@ -0,0 +1,64 @@
+package jsonrpc
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Mount adds the methods registered with other to h, with their names
+// prefixed by prefix, so that handlers built independently, such as by
+// separate modules, may be served from one endpoint. For example, after
+// h.Mount("billing.", billing), a method registered with billing as "Pay" is
+// called as "billing.Pay".
+//
+// Mounted methods keep the middleware, error handlers and metadata they were
+// registered with on other; the middleware of h does not apply to them. Within
+// that middleware, MethodFromContext returns the unprefixed name, so middleware
+// keyed by method name, such as SchemaMiddleware, works unchanged. Other
+// options, such as DumpErrors, are those of h. Methods registered with other
+// after it is mounted are not added to h.
+//
+// Mount panics, without adding any methods, if any of the prefixed names is
+// already registered with h, naming the prefix it was mounted under, if any.
+func (h *Handler) Mount(prefix string, other *Handler) {
+	if err := h.mount(prefix, other); err != nil {
+		panic(err.Error())
+	}
+}
+
+func (h *Handler) mount(prefix string, other *Handler) error {
+	other.mu.RLock()
+	methods := make([]method, 0, len(other.methods))
+	for _, m := range other.methods {
+		methods = append(methods, m)
+	}
+	other.mu.RUnlock()
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i].seq < methods[j].seq
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, m := range methods {
+		name := prefix + m.Name
+		if old, ok := h.methods[h.methodKey(name)]; ok {
+			if old.mount != nil {
+				return fmt.Errorf("jsonrpc: cannot mount %s under %q: already mounted under %q as %s",
+					m.Name, prefix, *old.mount, old.Name)
+			}
+			return fmt.Errorf("jsonrpc: cannot mount %s under %q: method already registered: %s",
+				m.Name, prefix, old.Name)
+		}
+	}
+	for _, m := range methods {
+		info := *m.info
+		info.Name = prefix + m.Name
+		m.Name = info.Name
+		m.info = &info
+		m.mount = &prefix
+		h.registered++
+		m.seq = h.registered
+		h.methods[h.methodKey(m.Name)] = m
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestMount(t *testing.T) {
+	billing := jsonrpc.New()
+	billing.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			jsonrpc.AddWarning(ctx, "billing middleware")
+			return next(ctx, params)
+		}
+	})
+	billing.RegisterWithMeta(jsonrpc.Methods{
+		"Pay": func(ctx context.Context, amount int) (interface{}, error) {
+			info := jsonrpc.MethodInfoFromContext(ctx)
+			return jsonrpc.M{"name": info.Name, "public": info.Meta.Public, "amount": amount}, nil
+		},
+	}, map[string]jsonrpc.Meta{
+		"Pay": {Public: true},
+	})
+
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Ping": func(ctx context.Context) (interface{}, error) {
+			return "pong", nil
+		},
+	})
+	server.Mount("billing.", billing)
+
+	resp := do(server, `{"id": 1, "method": "billing.Pay", "params": 5}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"result": {"name": "billing.Pay", "public": true, "amount": 5},
+		"warnings": ["billing middleware"]
+	}`)
+
+	resp = do(server, `{"id": 1, "method": "Pay", "params": 5}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {"name": "method_not_found", "message": "method not found: Pay"}
+	}`)
+
+	resp = do(server, `{"id": 1, "method": "Ping"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "pong"}`)
+
+	mountPanic := func(prefix string, other *jsonrpc.Handler) (gotPanic interface{}) {
+		defer func() { gotPanic = recover() }()
+		server.Mount(prefix, other)
+		return nil
+	}
+
+	// Conflicts name the prefix the existing method was mounted under.
+	payments := jsonrpc.New()
+	payments.Register(jsonrpc.Methods{
+		"Pay":    func(ctx context.Context) (interface{}, error) { return nil, nil },
+		"Refund": func(ctx context.Context) (interface{}, error) { return nil, nil },
+	})
+	assert.Equal(t, mountPanic("billing.", payments),
+		`jsonrpc: cannot mount Pay under "billing.": already mounted under "billing." as billing.Pay`)
+
+	ping := jsonrpc.New()
+	ping.Register(jsonrpc.Methods{
+		"Ping": func(ctx context.Context) (interface{}, error) { return nil, nil },
+	})
+	assert.Equal(t, mountPanic("", ping),
+		`jsonrpc: cannot mount Ping under "": method already registered: Ping`)
+
+	// Nothing is mounted if there is a conflict.
+	resp = do(server, `{"id": 1, "method": "billing.Refund"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {"name": "method_not_found", "message": "method not found: billing.Refund"}
+	}`)
+}
+
+func TestMountSchemaMiddleware(t *testing.T) {
+	type params struct {
+		Size string `json:"size"`
+	}
+	billing := jsonrpc.New()
+	billing.Use(jsonrpc.SchemaMiddleware(map[string]jsonrpc.Schema{
+		"Pay": enumSchema{"size": {"small", "large"}},
+	}))
+	billing.Register(jsonrpc.Methods{
+		"Pay": func(ctx context.Context, p params) (interface{}, error) {
+			return jsonrpc.MethodFromContext(ctx), nil
+		},
+	})
+
+	server := jsonrpc.New()
+	server.Mount("billing.", billing)
+
+	resp := do(server, `{"id": 1, "method": "billing.Pay", "params": {"size": "small"}}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "Pay"}`)
+
+	resp = do(server, `{"id": 1, "method": "billing.Pay", "params": {"size": "huge"}}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {
+			"name": "invalid_params",
+			"message": "params failed validation",
+			"data": [{"field": "size", "message": "invalid value"}]
+		}
+	}`)
+
+	// Overriding a mounted method keeps its middleware and prefixed name.
+	server.Override("billing.Pay", func(ctx context.Context, p params) (interface{}, error) {
+		return jsonrpc.MethodInfoFromContext(ctx).Name, nil
+	})
+	resp = do(server, `{"id": 1, "method": "billing.Pay", "params": {"size": "small"}}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "billing.Pay"}`)
+	resp = do(server, `{"id": 1, "method": "billing.Pay", "params": {"size": "huge"}}`)
+	assert.Equal(t, strings.Contains(resp.Body.String(), "params failed validation"), true)
+}
+
+func requireAdmin(next jsonrpc.Next) jsonrpc.Next {
+	return func(ctx context.Context, params interface{}) (interface{}, error) {
+		return nil, jsonrpc.Unauthorized("admin only")
+	}
+}
+
+func TestMountUseForOverride(t *testing.T) {
+	billing := jsonrpc.New()
+	billing.UseFor("admin.*", requireAdmin)
+	billing.Register(jsonrpc.Methods{
+		"admin.Delete": func(ctx context.Context) (interface{}, error) {
+			return "deleted", nil
+		},
+	})
+	server := jsonrpc.New()
+	server.Mount("billing.", billing)
+
+	const unauthorized = `{
+		"id": 1,
+		"error": {"name": "unauthorized", "message": "admin only"}
+	}`
+	resp := do(server, `{"id": 1, "method": "billing.admin.Delete"}`)
+	assert.JSONEqual(t, resp.Body.String(), unauthorized)
+
+	// Middleware matched by pattern still applies once the method is
+	// overridden.
+	server.Override("billing.admin.Delete", func(ctx context.Context) (interface{}, error) {
+		return "overridden", nil
+	})
+	resp = do(server, `{"id": 1, "method": "billing.admin.Delete"}`)
+	assert.JSONEqual(t, resp.Body.String(), unauthorized)
+	assert.Equal(t, server.MiddlewareChain("billing.admin.Delete"), []string{
+		"github.com/deliveroo/jsonrpc-go_test.requireAdmin",
+	})
+}
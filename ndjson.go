@@ -0,0 +1,50 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// acceptsNDJSON reports whether r accepts newline-delimited JSON responses.
+func acceptsNDJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(accept)
+		if err == nil && mediaType == "application/x-ndjson" {
+			return true
+		}
+	}
+	return false
+}
+
+// serveBatchStream invokes a batch of requests, writing each response as
+// newline-delimited JSON as soon as it is ready. If a response cannot be
+// encoded, an internal_error response is written in its place; responses to
+// notifications are not written. It returns the responses, in the same order
+// as requests.
+func (h *Handler) serveBatchStream(ctx context.Context, w http.ResponseWriter, r *http.Request, requests []*request) []*response {
+	w.Header().Set("content-type", "application/x-ndjson; charset=utf-8")
+	w.WriteHeader(200)
+
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	responses := make([]*response, 0, len(requests))
+	h.dispatchEach(ctx, r, requests, true, nil, func(req *request, resp *response) {
+		if resp.noReply {
+			responses = append(responses, resp)
+			return
+		}
+		if err := enc.Encode(resp); err != nil {
+			rpcErr := h.prepareError(r, req.Method, InternalError(err))
+			resp = &response{ID: resp.ID, Error: rpcErr}
+			_ = enc.Encode(resp)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		responses = append(responses, resp)
+	})
+	return responses
+}
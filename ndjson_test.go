@@ -0,0 +1,62 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestStreamBatches(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Echo": func(ctx context.Context, s string) (interface{}, error) {
+			return s, nil
+		},
+		"Invalid": func(ctx context.Context) (interface{}, error) {
+			return func() {}, nil
+		},
+	})
+	batch := `[
+		{"id": 1, "method": "Echo", "params": "a"},
+		{"method": "Echo", "params": "b"},
+		{"id": 2, "method": "Missing"},
+		{"id": 3, "method": "Invalid"}
+	]`
+	send := func(accept, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		resp := send("application/x-ndjson", `[{"id": 1, "method": "Echo", "params": "a"}]`)
+		assert.Equal(t, resp.Header().Get("content-type"), "application/json; charset=utf-8")
+	})
+
+	server.StreamBatches = true
+
+	t.Run("streamed", func(t *testing.T) {
+		resp := send("application/json;q=0.5, application/x-ndjson", batch)
+		assert.Equal(t, resp.Result().StatusCode, 200)
+		assert.Equal(t, resp.Header().Get("content-type"), "application/x-ndjson; charset=utf-8")
+		assert.Equal(t, resp.Flushed, true)
+		assert.Equal(t, resp.Body.String(), strings.Join([]string{
+			`{"result":"a","id":1}`,
+			`{"error":{"name":"method_not_found","message":"method not found: Missing"},"id":2}`,
+			`{"error":{"name":"internal_error","message":"internal error","retryable":true},"id":3}`,
+			``,
+		}, "\n"))
+	})
+
+	t.Run("not accepted", func(t *testing.T) {
+		resp := send("application/json", `[{"id": 1, "method": "Echo", "params": "a"}]`)
+		assert.Equal(t, resp.Header().Get("content-type"), "application/json; charset=utf-8")
+	})
+}
@@ -0,0 +1,48 @@
+package jsonrpc
+
+import (
+	"context"
+	"strconv"
+)
+
+// NonceStore records nonces for NonceMiddleware.
+type NonceStore interface {
+	// Add records nonce, reporting false if it was already recorded. Stores
+	// are expected to forget nonces after a TTL window, which should be at
+	// least as long as the window in which signed requests are accepted.
+	Add(ctx context.Context, nonce string) (added bool, err error)
+}
+
+// NonceMiddleware returns middleware that protects methods against replayed
+// requests. Each HTTP request must carry a unique X-Nonce header; requests
+// with a missing nonce, or a nonce that has been seen before, are rejected
+// with an unauthorized error.
+//
+// Within a batch, the position of each request is appended to the nonce, so
+// a batch is accepted once, as a whole. Nonces are only meaningful when the
+// request body is signed, so that a replayed request can't be altered.
+func NonceMiddleware(store NonceStore) Middleware {
+	return func(next Next) Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			var nonce string
+			if r := RequestFromContext(ctx); r != nil {
+				nonce = r.Header.Get("X-Nonce")
+			}
+			if nonce == "" {
+				return nil, Unauthorized("nonce required")
+			}
+			if index, _, ok := BatchInfoFromContext(ctx); ok {
+				nonce += "/" + strconv.Itoa(index)
+			}
+
+			added, err := store.Add(ctx, nonce)
+			if err != nil {
+				return nil, err
+			}
+			if !added {
+				return nil, Unauthorized("nonce reused")
+			}
+			return next(ctx, params)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+type nonceSet map[string]bool
+
+func (s nonceSet) Add(ctx context.Context, nonce string) (bool, error) {
+	if s[nonce] {
+		return false, nil
+	}
+	s[nonce] = true
+	return true, nil
+}
+
+func TestNonceMiddleware(t *testing.T) {
+	server := jsonrpc.New()
+	server.Use(jsonrpc.NonceMiddleware(nonceSet{}))
+	server.Register(jsonrpc.Methods{
+		"Transfer": func(ctx context.Context) (interface{}, error) {
+			return "ok", nil
+		},
+	})
+
+	doWithNonce := func(nonce, body string) string {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		if nonce != "" {
+			req.Header.Set("X-Nonce", nonce)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	const single = `{"id": 1, "method": "Transfer"}`
+	assert.JSONEqual(t, doWithNonce("", single), `{
+		"id": 1,
+		"error": {"name": "unauthorized", "message": "nonce required"}
+	}`)
+	assert.JSONEqual(t, doWithNonce("a", single), `{"id": 1, "result": "ok"}`)
+	assert.JSONEqual(t, doWithNonce("a", single), `{
+		"id": 1,
+		"error": {"name": "unauthorized", "message": "nonce reused"}
+	}`)
+
+	const batch = `[{"id": 1, "method": "Transfer"}, {"id": 2, "method": "Transfer"}]`
+	assert.JSONEqual(t, doWithNonce("b", batch), `[
+		{"id": 1, "result": "ok"},
+		{"id": 2, "result": "ok"}
+	]`)
+	assert.JSONEqual(t, doWithNonce("b", batch), `[
+		{"id": 1, "error": {"name": "unauthorized", "message": "nonce reused"}},
+		{"id": 2, "error": {"name": "unauthorized", "message": "nonce reused"}}
+	]`)
+}
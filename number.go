@@ -0,0 +1,17 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// unmarshalParams unmarshals raw params into v, decoding numbers within
+// interface{} values as json.Number rather than float64 if UseNumber is set.
+func (h *Handler) unmarshalParams(raw json.RawMessage, v interface{}) error {
+	if !h.UseNumber || len(raw) == 0 {
+		return json.Unmarshal(raw, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
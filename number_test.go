@@ -0,0 +1,43 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestUseNumber(t *testing.T) {
+	for _, useNumber := range []bool{false, true} {
+		t.Run(fmt.Sprintf("UseNumber=%v", useNumber), func(t *testing.T) {
+			server := jsonrpc.New()
+			server.UseNumber = useNumber
+			server.Register(jsonrpc.Methods{
+				"Describe": func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+					return fmt.Sprintf("%T %v", params["amount"], params["amount"]), nil
+				},
+				"Amount": func(ctx context.Context, params struct {
+					Amount json.Number `json:"amount"`
+				}) (interface{}, error) {
+					return params.Amount.String(), nil
+				},
+			})
+
+			want := `"float64 1.2345678901234567e+19"`
+			if useNumber {
+				want = `"json.Number 12345678901234567890"`
+			}
+			resp := do(server, `{"id": 1, "method": "Describe", "params": {"amount": 12345678901234567890}}`)
+			assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": `+want+`}`)
+
+			resp = do(server, `{"id": 1, "method": "Amount", "params": {"amount": 0.10000000000000000001}}`)
+			assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "0.10000000000000000001"}`)
+
+			resp = do(server, `{"id": 1, "method": "Describe", "params": {"amount": "x"`)
+			assert.Equal(t, resp.Result().StatusCode, 400)
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package jsonrpc
+
+// PartialResult is a method result that renders both a result and an error in
+// the response, e.g. for a method that processes many items, some of which
+// fail. This is a deliberate extension to JSON-RPC, in which responses have
+// either a result or an error, so methods opt in by returning a PartialResult.
+// Responses with a PartialResult always have HTTP status 200.
+//
+// If Error is nil, the response is rendered as if Result had been returned
+// directly.
+type PartialResult struct {
+	Result interface{}
+	Error  *RPCError
+}
+
+// splitPartial returns the result and error of result if it is a
+// PartialResult, or result and nil otherwise.
+func splitPartial(result interface{}) (interface{}, *RPCError) {
+	if pr, ok := result.(PartialResult); ok {
+		return pr.Result, pr.Error
+	}
+	return result, nil
+}
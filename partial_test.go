@@ -0,0 +1,42 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestPartialResult(t *testing.T) {
+	server := jsonrpc.New()
+	server.ErrorStatusMode = jsonrpc.MapFromName
+	server.Register(jsonrpc.Methods{
+		"Import": func(ctx context.Context, items []string) (interface{}, error) {
+			var imported, failed []string
+			for _, item := range items {
+				if item == "" {
+					failed = append(failed, item)
+					continue
+				}
+				imported = append(imported, item)
+			}
+			result := jsonrpc.PartialResult{Result: jsonrpc.M{"imported": imported}}
+			if len(failed) > 0 {
+				result.Error = jsonrpc.InvalidParams("%d items failed", len(failed))
+			}
+			return result, nil
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "Import", "params": ["a", "", "b"]}`)
+	assert.Equal(t, resp.Code, 200)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"result": {"imported": ["a", "b"]},
+		"error": {"name": "invalid_params", "message": "1 items failed"}
+	}`)
+
+	resp = do(server, `{"id": 1, "method": "Import", "params": ["a"]}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": {"imported": ["a"]}}`)
+}
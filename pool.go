@@ -0,0 +1,38 @@
+package jsonrpc
+
+import "sync"
+
+// Responses, and the slices holding them, are pooled to save allocating them
+// for each request. Only ServeHTTP returns them to the pools, once they have
+// been encoded; those used by other transports are garbage collected.
+var (
+	responsePool  = sync.Pool{New: func() interface{} { return new(response) }}
+	responsesPool = sync.Pool{New: func() interface{} { return new([]*response) }}
+)
+
+// newResponse returns a zeroed response from the pool.
+func newResponse() *response {
+	return responsePool.Get().(*response)
+}
+
+// newResponses returns an empty slice of responses from the pool, with
+// capacity for at least n responses.
+func newResponses(n int) []*response {
+	responses := *responsesPool.Get().(*[]*response)
+	if cap(responses) < n {
+		return make([]*response, 0, n)
+	}
+	return responses[:0]
+}
+
+// releaseResponses resets responses, and returns them and the slice holding
+// them to the pools. They must not be used afterwards.
+func releaseResponses(responses []*response) {
+	for i, resp := range responses {
+		*resp = response{}
+		responsePool.Put(resp)
+		responses[i] = nil
+	}
+	responses = responses[:0]
+	responsesPool.Put(&responses)
+}
@@ -0,0 +1,53 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestResponsePooling(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Noisy": func(ctx context.Context) (interface{}, error) {
+			jsonrpc.AddWarning(ctx, "noisy")
+			jsonrpc.SetResponseMeta(ctx, "noisy", true)
+			return "partial", jsonrpc.NotFound("not found")
+		},
+		"Echo": func(ctx context.Context, n int) (interface{}, error) {
+			return n, nil
+		},
+	})
+
+	// Responses reused from the pool carry nothing over from earlier ones.
+	for i := 0; i < 10; i++ {
+		do(server, `[{"id": 1, "method": "Noisy"}, {"id": 2, "method": "Noisy"}]`)
+		resp := do(server, `{"id": 3, "method": "Echo", "params": 1}`)
+		assert.JSONEqual(t, resp.Body.String(), `{"id": 3, "result": 1}`)
+	}
+
+	// Concurrent requests get their own responses.
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n := strconv.Itoa(i)
+			resp := do(server, `[{"id": 1, "method": "Echo", "params": `+n+`}, {"id": 2, "method": "Noisy"}]`)
+			assert.JSONEqual(t, resp.Body.String(), `[
+				{"id": 1, "result": `+n+`},
+				{
+					"id": 2,
+					"error": {"name": "not_found", "message": "not found"},
+					"warnings": ["noisy"],
+					"meta": {"noisy": true}
+				}
+			]`)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,59 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// PositionalParams may be implemented by the params type of a method to also
+// accept params as an array of positional arguments, e.g. while migrating
+// clients from positional to named params. Params are first unmarshaled as
+// usual; if they are an array that cannot be unmarshaled into the type,
+// UnmarshalPositional is called with the elements of the array instead.
+//
+// For example:
+//
+//	type helloParams struct {
+//		Name string `json:"name"`
+//	}
+//
+//	func (p *helloParams) UnmarshalPositional(args []json.RawMessage) error {
+//		if len(args) != 1 {
+//			return jsonrpc.InvalidParams("expected 1 argument")
+//		}
+//		return json.Unmarshal(args[0], &p.Name)
+//	}
+type PositionalParams interface {
+	UnmarshalPositional(args []json.RawMessage) error
+}
+
+// unmarshalPositional attempts to unmarshal raw into params, a pointer
+// allocated by method.newParams, as positional params, after err occurred
+// unmarshaling it as usual. It reports false if params doesn't implement
+// PositionalParams or raw isn't an array.
+func unmarshalPositional(raw json.RawMessage, params interface{}, err error) (bool, error) {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Value != "array" {
+		return false, nil
+	}
+
+	// Find the innermost pointer, allocating any nil pointers along the way.
+	v := reflect.ValueOf(params)
+	for v.Elem().Kind() == reflect.Ptr {
+		if v.Elem().IsNil() {
+			v.Elem().Set(reflect.New(v.Elem().Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	p, ok := v.Interface().(PositionalParams)
+	if !ok {
+		return false, nil
+	}
+
+	var args []json.RawMessage
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return true, err
+	}
+	return true, p.UnmarshalPositional(args)
+}
@@ -0,0 +1,57 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+type greetParams struct {
+	Name  string `json:"name"`
+	Times int    `json:"times"`
+}
+
+func (p *greetParams) UnmarshalPositional(args []json.RawMessage) error {
+	if len(args) != 2 {
+		return jsonrpc.InvalidParams("expected 2 arguments, got %d", len(args))
+	}
+	if err := json.Unmarshal(args[0], &p.Name); err != nil {
+		return err
+	}
+	return json.Unmarshal(args[1], &p.Times)
+}
+
+func TestPositionalParams(t *testing.T) {
+	greet := func(ctx context.Context, params greetParams) (interface{}, error) {
+		return fmt.Sprintf("%s x%d", params.Name, params.Times), nil
+	}
+	greetPtr := func(ctx context.Context, params *greetParams) (interface{}, error) {
+		return greet(ctx, *params)
+	}
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Greet":    greet,
+		"GreetPtr": greetPtr,
+	})
+
+	resp := do(server, `[
+		{"id": 1, "method": "Greet", "params": {"name": "Alice", "times": 2}},
+		{"id": 2, "method": "Greet", "params": ["Bob", 3]},
+		{"id": 3, "method": "GreetPtr", "params": ["Carol", 1]},
+		{"id": 4, "method": "Greet", "params": ["Dave"]},
+		{"id": 5, "method": "Greet", "params": [1, 2]},
+		{"id": 6, "method": "Greet", "params": "Eve"}
+	]`)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{"id": 1, "result": "Alice x2"},
+		{"id": 2, "result": "Bob x3"},
+		{"id": 3, "result": "Carol x1"},
+		{"id": 4, "error": {"name": "invalid_params", "message": "expected 2 arguments, got 1"}},
+		{"id": 5, "error": {"name": "parse_error", "message": "cannot parse params: offset 1: cannot unmarshal number as string"}},
+		{"id": 6, "error": {"name": "parse_error", "message": "cannot parse params: offset 5: cannot unmarshal string as object"}}
+	]`)
+}
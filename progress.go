@@ -0,0 +1,32 @@
+package jsonrpc
+
+import "context"
+
+// ProgressMethod is the method of the notifications sent by Progress.
+const ProgressMethod = "rpc.progress"
+
+// progress is the params of a progress notification.
+type progress struct {
+	ID      interface{} `json:"id"`
+	Percent float64     `json:"percent"`
+	Message string      `json:"message,omitempty"`
+}
+
+// Progress reports the progress of a long-running method to the client, as a
+// notification like:
+//
+//	{"method": "rpc.progress", "params": {"id": 1, "percent": 50, "message": "..."}}
+//
+// where id is the id of the request. Notifications are flushed to the client
+// as they're sent, so can only be sent when the response is streamed, such as
+// for streaming methods called over HTTP, and for requests outside of a batch
+// served by ServeStdio. Otherwise, Progress is a no-op, so that the same
+// method may be served by any transport. Once the response has been written,
+// Progress returns ErrStreamClosed.
+func Progress(ctx context.Context, percent float64, msg string) error {
+	s := stateFromContext(ctx)
+	if s == nil || s.notify == nil {
+		return nil
+	}
+	return s.notify(ProgressMethod, progress{ID: s.id, Percent: percent, Message: msg})
+}
@@ -0,0 +1,77 @@
+package jsonrpc_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestProgress(t *testing.T) {
+	work := func(ctx context.Context) (interface{}, error) {
+		for _, p := range []float64{0, 50} {
+			if err := jsonrpc.Progress(ctx, p, "working"); err != nil {
+				return nil, err
+			}
+		}
+		return "done", nil
+	}
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Work": work,
+		"StreamWork": func(ctx context.Context, emit jsonrpc.Emit) (interface{}, error) {
+			return work(ctx)
+		},
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		resp := do(server, `{"id": 1, "method": "StreamWork"}`)
+		assert.Equal(t, resp.Body.String(), strings.Join([]string{
+			`{"method":"rpc.progress","params":{"id":1,"percent":0,"message":"working"}}`,
+			`{"method":"rpc.progress","params":{"id":1,"percent":50,"message":"working"}}`,
+			`{"result":"done","id":1}`,
+			``,
+		}, "\n"))
+	})
+
+	t.Run("buffered", func(t *testing.T) {
+		resp := do(server, `{"id": 1, "method": "Work"}`)
+		assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "done"}`)
+
+		resp = do(server, `[{"id": 1, "method": "Work"}, {"id": 2, "method": "Work"}]`)
+		assert.JSONEqual(t, resp.Body.String(), `[{"id": 1, "result": "done"}, {"id": 2, "result": "done"}]`)
+	})
+
+	t.Run("stdio", func(t *testing.T) {
+		var out bytes.Buffer
+		in := frame(`{"id": "a", "method": "Work"}`)
+		assert.Must(t, server.ServeStdio(context.Background(), strings.NewReader(in), &out))
+		assert.Equal(t, out.String(), ""+
+			frame(`{"method":"rpc.progress","params":{"id":"a","percent":0,"message":"working"}}`)+
+			frame(`{"method":"rpc.progress","params":{"id":"a","percent":50,"message":"working"}}`)+
+			frame(`{"result":"done","id":"a"}`))
+	})
+
+	t.Run("after response", func(t *testing.T) {
+		var methodCtx context.Context
+		server := jsonrpc.New()
+		server.Register(jsonrpc.Methods{
+			"Start": func(ctx context.Context) (interface{}, error) {
+				methodCtx = ctx
+				return "started", nil
+			},
+		})
+		var out bytes.Buffer
+		in := frame(`{"id": 1, "method": "Start"}`)
+		assert.Must(t, server.ServeStdio(context.Background(), strings.NewReader(in), &out))
+		assert.Equal(t, jsonrpc.Progress(methodCtx, 100, "late"), jsonrpc.ErrStreamClosed)
+		assert.Equal(t, out.String(), frame(`{"result":"started","id":1}`))
+	})
+
+	t.Run("outside handler", func(t *testing.T) {
+		assert.Must(t, jsonrpc.Progress(context.Background(), 100, ""))
+	})
+}
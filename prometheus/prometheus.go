@@ -0,0 +1,57 @@
+// Package prometheus exports the metrics of JSON-RPC method calls to
+// Prometheus. It is a separate module, so that the jsonrpc package itself
+// does not depend on the Prometheus client.
+package prometheus
+
+import (
+	"time"
+
+	"github.com/deliveroo/jsonrpc-go"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// Middleware returns middleware that records the following metrics of each
+// method call, which it registers with reg:
+//
+//	rpc_requests_total{method,error}      counter of calls, by error name, or "" if successful
+//	rpc_request_duration_seconds{method}  histogram of call durations
+//	rpc_requests_in_flight                gauge of calls in progress
+//
+// It is built on jsonrpc.MetricsMiddleware, so errors that are not RPCErrors
+// are recorded as "internal_error". Middleware panics if the metrics cannot
+// be registered, such as when it is called twice with the same reg.
+func Middleware(reg prom.Registerer) jsonrpc.Middleware {
+	rec := &recorder{
+		requests: prom.NewCounterVec(prom.CounterOpts{
+			Name: "rpc_requests_total",
+			Help: "Total number of JSON-RPC method calls, by method and error.",
+		}, []string{"method", "error"}),
+		duration: prom.NewHistogramVec(prom.HistogramOpts{
+			Name:    "rpc_request_duration_seconds",
+			Help:    "Duration of JSON-RPC method calls, by method.",
+			Buckets: prom.DefBuckets,
+		}, []string{"method"}),
+		inFlight: prom.NewGauge(prom.GaugeOpts{
+			Name: "rpc_requests_in_flight",
+			Help: "Number of JSON-RPC method calls in progress.",
+		}),
+	}
+	reg.MustRegister(rec.requests, rec.duration, rec.inFlight)
+	return jsonrpc.MetricsMiddleware(rec)
+}
+
+// recorder is a jsonrpc.MetricsRecorder that updates Prometheus metrics.
+type recorder struct {
+	requests *prom.CounterVec
+	duration *prom.HistogramVec
+	inFlight prom.Gauge
+}
+
+func (r *recorder) InFlight(delta int) {
+	r.inFlight.Add(float64(delta))
+}
+
+func (r *recorder) Observe(method, errName string, d time.Duration) {
+	r.requests.WithLabelValues(method, errName).Inc()
+	r.duration.WithLabelValues(method).Observe(d.Seconds())
+}
@@ -0,0 +1,49 @@
+package prometheus_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+	"github.com/deliveroo/jsonrpc-go/prometheus"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	reg := prom.NewRegistry()
+	server := jsonrpc.New()
+	server.Use(prometheus.Middleware(reg))
+	server.Register(jsonrpc.Methods{
+		"Ping": func(ctx context.Context) (interface{}, error) {
+			return "pong", nil
+		},
+		"Broken": func(ctx context.Context) (interface{}, error) {
+			return nil, errors.New("broken")
+		},
+	})
+
+	body := `[
+		{"id": 1, "method": "Ping"},
+		{"id": 2, "method": "Ping"},
+		{"id": 3, "method": "Broken"}
+	]`
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	err := testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP rpc_requests_total Total number of JSON-RPC method calls, by method and error.
+		# TYPE rpc_requests_total counter
+		rpc_requests_total{error="",method="Ping"} 2
+		rpc_requests_total{error="internal_error",method="Broken"} 1
+		# HELP rpc_requests_in_flight Number of JSON-RPC method calls in progress.
+		# TYPE rpc_requests_in_flight gauge
+		rpc_requests_in_flight 0
+	`), "rpc_requests_total", "rpc_requests_in_flight")
+	assert.Must(t, err)
+	assert.Equal(t, testutil.CollectAndCount(reg, "rpc_request_duration_seconds"), 2)
+}
@@ -0,0 +1,105 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// DecodeQuery decodes query parameters, such as those of a GET request, into
+// params, which must be a pointer to a struct. Each parameter is matched to a
+// field by its JSON name, as by encoding/json, and its value is converted to
+// the field's type, so that ?id=5&active=true decodes into int and bool
+// fields. Fields of slice type take every value of their parameter, and other
+// fields the first. Values for fields of object type must be JSON, and values
+// for durations are parsed by time.ParseDuration. Parameters without a field
+// are ignored.
+//
+// Values that cannot be converted result in an invalid_params error.
+func DecodeQuery(values url.Values, params interface{}) error {
+	val := reflect.ValueOf(params)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return errors.New("jsonrpc: DecodeQuery requires a pointer to a struct")
+	}
+	t := val.Elem().Type()
+
+	obj := make(map[string]interface{}, len(values))
+	for key, vals := range values {
+		ft := fieldType(t, key)
+		if ft == nil || len(vals) == 0 {
+			continue
+		}
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Slice && jsonType(ft) == "array" {
+			elems := make([]interface{}, len(vals))
+			for i, v := range vals {
+				elem, err := queryValue(v, ft.Elem())
+				if err != nil {
+					return InvalidParams("invalid value for %s: %q", key, v)
+				}
+				elems[i] = elem
+			}
+			obj[key] = elems
+			continue
+		}
+		v, err := queryValue(vals[0], ft)
+		if err != nil {
+			return InvalidParams("invalid value for %s: %q", key, vals[0])
+		}
+		obj[key] = v
+	}
+
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return InvalidParams("cannot encode params").Wrap(err)
+	}
+	if err := json.Unmarshal(raw, params); err != nil {
+		return ParseError(err, "cannot parse params")
+	}
+	return nil
+}
+
+// queryValue converts the query parameter value s into the JSON value that
+// would be unmarshaled into type t.
+func queryValue(s string, t reflect.Type) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(typeJSONUnmarshaler) && t != typeTimeDuration {
+		return s, nil // custom decoding, such as time.Time, from a string
+	}
+	switch jsonType(t) {
+	case "integer":
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+			if _, err := strconv.ParseUint(s, 10, 64); err != nil {
+				return nil, err
+			}
+		}
+		return json.Number(s), nil
+	case "number":
+		n := json.Number(s)
+		if _, err := n.Float64(); err != nil || !json.Valid([]byte(n)) {
+			return nil, errors.New("invalid number")
+		}
+		return n, nil
+	case "boolean":
+		return strconv.ParseBool(s)
+	case "duration":
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, err
+		}
+		return int64(d), nil
+	case "object", "array":
+		if !json.Valid([]byte(s)) {
+			return nil, errors.New("invalid JSON")
+		}
+		return json.RawMessage(s), nil
+	}
+	return s, nil
+}
@@ -0,0 +1,65 @@
+package jsonrpc_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestDecodeQuery(t *testing.T) {
+	type params struct {
+		Name    string            `json:"name"`
+		Count   int               `json:"count"`
+		Price   *float64          `json:"price"`
+		Active  bool              `json:"active"`
+		Tags    []string          `json:"tags"`
+		IDs     []int             `json:"ids"`
+		Since   time.Time         `json:"since"`
+		Timeout time.Duration     `json:"timeout"`
+		Filter  map[string]string `json:"filter"`
+	}
+
+	query, err := url.ParseQuery("name=Alice&count=5&price=9.99&active=true" +
+		"&tags=a&tags=b&ids=1&ids=2&since=2020-01-02T03:04:05Z&timeout=1m30s" +
+		"&filter=%7B%22city%22%3A%22London%22%7D&unknown=x")
+	assert.Must(t, err)
+	var got params
+	assert.Must(t, jsonrpc.DecodeQuery(query, &got))
+	price := 9.99
+	assert.Equal(t, got, params{
+		Name:    "Alice",
+		Count:   5,
+		Price:   &price,
+		Active:  true,
+		Tags:    []string{"a", "b"},
+		IDs:     []int{1, 2},
+		Since:   time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Timeout: 90 * time.Second,
+		Filter:  map[string]string{"city": "London"},
+	})
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"count=five", `jsonrpc: invalid params: invalid value for count: "five"`},
+		{"ids=1&ids=x", `jsonrpc: invalid params: invalid value for ids: "x"`},
+		{"active=maybe", `jsonrpc: invalid params: invalid value for active: "maybe"`},
+		{"filter=%7B", `jsonrpc: invalid params: invalid value for filter: "{"`},
+		{"count=1e400", `jsonrpc: invalid params: invalid value for count: "1e400"`},
+		{"count=1.5", `jsonrpc: invalid params: invalid value for count: "1.5"`},
+		{"price=1e400", `jsonrpc: invalid params: invalid value for price: "1e400"`},
+	}
+	for _, tt := range tests {
+		query, err := url.ParseQuery(tt.query)
+		assert.Must(t, err)
+		err = jsonrpc.DecodeQuery(query, &params{})
+		assert.Equal(t, err.Error(), tt.err)
+	}
+
+	err = jsonrpc.DecodeQuery(query, map[string]string{})
+	assert.Equal(t, err.Error(), "jsonrpc: DecodeQuery requires a pointer to a struct")
+}
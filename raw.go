@@ -0,0 +1,43 @@
+package jsonrpc
+
+import "net/http"
+
+// RawResponse is a method result that is written to the client as-is, with
+// the given content type and status, rather than being rendered as JSON within
+// a response object. It is useful for binary results, such as generated PDFs.
+//
+// A method returning a RawResponse cannot be called as part of a batch. Over
+// transports other than HTTP, such as ServeStdio, it is rendered as JSON.
+type RawResponse struct {
+	// ContentType is the value of the response's Content-Type header.
+	ContentType string
+
+	// Status is the HTTP status of the response. Defaults to 200.
+	Status int
+
+	// Body is the body of the response.
+	Body []byte
+}
+
+// asRawResponse returns v as a *RawResponse, if it is one.
+func asRawResponse(v interface{}) (*RawResponse, bool) {
+	switch v := v.(type) {
+	case RawResponse:
+		return &v, true
+	case *RawResponse:
+		return v, v != nil
+	default:
+		return nil, false
+	}
+}
+
+// send writes the raw response to w.
+func (raw *RawResponse) send(w http.ResponseWriter) {
+	status := raw.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.Header().Set("content-type", raw.ContentType)
+	w.WriteHeader(status)
+	_, _ = w.Write(raw.Body)
+}
@@ -0,0 +1,53 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestRawResponse(t *testing.T) {
+	server := jsonrpc.New()
+	server.AllowFieldSelection = true
+	server.Register(jsonrpc.Methods{
+		"Report": func(ctx context.Context) (interface{}, error) {
+			jsonrpc.SetResponseHeader(ctx, "Content-Disposition", "attachment")
+			return jsonrpc.RawResponse{
+				ContentType: "application/pdf",
+				Body:        []byte("%PDF-1.4"),
+			}, nil
+		},
+		"Accepted": func(ctx context.Context) (interface{}, error) {
+			return &jsonrpc.RawResponse{
+				ContentType: "text/plain",
+				Status:      202,
+				Body:        []byte("queued"),
+			}, nil
+		},
+	})
+
+	t.Run("single", func(t *testing.T) {
+		resp := do(server, `{"id": 1, "method": "Report", "fields": ["Body"]}`)
+		assert.Equal(t, resp.Result().StatusCode, 200)
+		assert.Equal(t, resp.Header().Get("content-type"), "application/pdf")
+		assert.Equal(t, resp.Header().Get("Content-Disposition"), "attachment")
+		assert.Equal(t, resp.Body.String(), "%PDF-1.4")
+	})
+
+	t.Run("status", func(t *testing.T) {
+		resp := do(server, `{"id": 1, "method": "Accepted"}`)
+		assert.Equal(t, resp.Result().StatusCode, 202)
+		assert.Equal(t, resp.Header().Get("content-type"), "text/plain")
+		assert.Equal(t, resp.Body.String(), "queued")
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		resp := do(server, `[{"id": 1, "method": "Report"}, {"id": 2, "method": "Accepted"}]`)
+		assert.JSONEqual(t, resp.Body.String(), `[
+			{"id": 1, "error": {"name": "invalid_request", "message": "method Report cannot be called in a batch"}},
+			{"id": 2, "error": {"name": "invalid_request", "message": "method Accepted cannot be called in a batch"}}
+		]`)
+	})
+}
@@ -0,0 +1,152 @@
+package jsonrpc
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// redacted replaces the value of secret string fields.
+const redacted = "[REDACTED]"
+
+// secretTypes caches whether each type has secret fields.
+var secretTypes sync.Map // reflect.Type -> bool
+
+// RedactParams returns a copy of params with the values of its secret fields
+// masked, for logging and auditing. Struct fields are marked as secret with
+// the tag `jsonrpc:"secret"`, such as:
+//
+//	type loginParams struct {
+//		Username string `json:"username"`
+//		Password string `json:"password" jsonrpc:"secret"`
+//	}
+//
+// Secret strings are replaced with "[REDACTED]", and other secret values with
+// their zero value. Secret fields are found within nested structs, pointers,
+// slices, arrays and maps, but not within interface values. If params has no
+// secret fields, it is returned as is.
+func RedactParams(params interface{}) interface{} {
+	if params == nil || !hasSecrets(reflect.TypeOf(params)) {
+		return params
+	}
+	return redactValue(reflect.ValueOf(params)).Interface()
+}
+
+// isSecret reports whether f is tagged `jsonrpc:"secret"`.
+func isSecret(f reflect.StructField) bool {
+	for _, opt := range strings.Split(f.Tag.Get("jsonrpc"), ",") {
+		if opt == "secret" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSecrets reports whether values of type t may contain secret fields.
+func hasSecrets(t reflect.Type) bool {
+	if ok, found := secretTypes.Load(t); found {
+		return ok.(bool)
+	}
+	ok := typeHasSecrets(t, map[reflect.Type]bool{})
+	secretTypes.Store(t, ok)
+	return ok
+}
+
+func typeHasSecrets(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return typeHasSecrets(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue // unexported
+			}
+			if isSecret(f) || typeHasSecrets(f.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// redactValue returns a copy of v with its secret fields masked. Values that
+// cannot contain secret fields are shared rather than copied.
+func redactValue(v reflect.Value) reflect.Value {
+	t := v.Type()
+	if !hasSecrets(t) {
+		return v
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		result := reflect.New(t.Elem())
+		result.Elem().Set(redactValue(v.Elem()))
+		return result
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		result := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			result.Index(i).Set(redactValue(v.Index(i)))
+		}
+		return result
+	case reflect.Array:
+		result := reflect.New(t).Elem()
+		for i := 0; i < v.Len(); i++ {
+			result.Index(i).Set(redactValue(v.Index(i)))
+		}
+		return result
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		result := reflect.MakeMapWithSize(t, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			result.SetMapIndex(iter.Key(), redactValue(iter.Value()))
+		}
+		return result
+	case reflect.Struct:
+		result := reflect.New(t).Elem()
+		result.Set(v)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue // unexported
+			}
+			field := result.Field(i)
+			switch {
+			case isSecret(f):
+				redactField(field)
+			case hasSecrets(f.Type):
+				field.Set(redactValue(field))
+			}
+		}
+		return result
+	}
+	return v
+}
+
+// redactField masks the value of the secret field v in place. Empty values
+// are left as they are, since they reveal nothing.
+func redactField(v reflect.Value) {
+	switch {
+	case v.IsZero():
+	case v.Kind() == reflect.String:
+		v.SetString(redacted)
+	case v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.String:
+		s := reflect.New(v.Type().Elem())
+		s.Elem().SetString(redacted)
+		v.Set(s)
+	default:
+		v.Set(reflect.Zero(v.Type()))
+	}
+}
@@ -0,0 +1,52 @@
+package jsonrpc_test
+
+import (
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+type credentials struct {
+	Username string  `json:"username"`
+	Password string  `json:"password" jsonrpc:"secret"`
+	OTP      *string `json:"otp" jsonrpc:"secret"`
+	PIN      int     `json:"pin" jsonrpc:"secret"`
+}
+
+type loginParams struct {
+	Credentials credentials            `json:"credentials"`
+	Backups     []credentials          `json:"backups"`
+	Tokens      map[string]credentials `json:"tokens"`
+	Remember    bool                   `json:"remember"`
+}
+
+func TestRedactParams(t *testing.T) {
+	otp := "123456"
+	params := &loginParams{
+		Credentials: credentials{Username: "amy", Password: "hunter2", OTP: &otp, PIN: 1234},
+		Backups:     []credentials{{Username: "bob", Password: "swordfish"}},
+		Tokens:      map[string]credentials{"api": {Password: "abc"}},
+		Remember:    true,
+	}
+	redacted := jsonrpc.RedactParams(params).(*loginParams)
+
+	masked := "[REDACTED]"
+	assert.Equal(t, redacted, &loginParams{
+		Credentials: credentials{Username: "amy", Password: "[REDACTED]", OTP: &masked},
+		Backups:     []credentials{{Username: "bob", Password: "[REDACTED]"}},
+		Tokens:      map[string]credentials{"api": {Password: "[REDACTED]"}},
+		Remember:    true,
+	})
+
+	// The params themselves are left untouched.
+	assert.Equal(t, params.Credentials.Password, "hunter2")
+	assert.Equal(t, *params.Credentials.OTP, "123456")
+	assert.Equal(t, params.Backups[0].Password, "swordfish")
+	assert.Equal(t, params.Tokens["api"].Password, "abc")
+
+	// Params without secret fields are returned as is.
+	assert.Equal(t, jsonrpc.RedactParams(42), 42)
+	assert.Equal(t, jsonrpc.RedactParams(nil), nil)
+	assert.Equal(t, jsonrpc.RedactParams((*loginParams)(nil)), (*loginParams)(nil))
+}
@@ -0,0 +1,53 @@
+package jsonrpc
+
+import "context"
+
+// Renderer is implemented by method results that transform themselves before
+// they are rendered to the client, such as to hide fields the caller is not
+// authorized to see. This keeps presentation separate from the logic of the
+// method. Render is called as soon as the method returns, inside of all
+// middleware, with the context the method was called with, so it sees the
+// values added by middleware. Its result is passed to the middleware, and
+// rendered, in place of the original; an error fails the call as if the
+// method had returned it. Results that don't implement Renderer are rendered
+// as is. Results shared by SingleflightMiddleware are instead rendered there,
+// with the context of each caller.
+type Renderer interface {
+	Render(ctx context.Context) (interface{}, error)
+}
+
+// wrapRender returns a Next that renders the results of next, unless their
+// rendering has been deferred to SingleflightMiddleware.
+func wrapRender(next Next) Next {
+	return func(ctx context.Context, params interface{}) (interface{}, error) {
+		result, err := next(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		if s := stateFromContext(ctx); s != nil && s.renderLate {
+			return result, nil
+		}
+		return render(ctx, result)
+	}
+}
+
+// render returns result as rendered by its Render method, if it is a
+// Renderer, including within any Accepted and PartialResult wrappers.
+func render(ctx context.Context, result interface{}) (interface{}, error) {
+	var err error
+	result = mapResult(result, func(v interface{}) interface{} {
+		r, ok := v.(Renderer)
+		if !ok {
+			return v
+		}
+		rendered, renderErr := r.Render(ctx)
+		if renderErr != nil {
+			err = renderErr
+		}
+		return rendered
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
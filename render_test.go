@@ -0,0 +1,133 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+type account struct {
+	Name    string `json:"name"`
+	Balance int    `json:"balance,omitempty"`
+}
+
+func (a account) Render(ctx context.Context) (interface{}, error) {
+	if a.Name == "" {
+		return nil, errors.New("account has no name")
+	}
+	if ctx.Value(adminKey{}) == nil {
+		a.Balance = 0
+	}
+	return a, nil
+}
+
+type adminKey struct{}
+
+func TestRenderer(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Account": func(ctx context.Context) (interface{}, error) {
+			return account{Name: "amy", Balance: 100}, nil
+		},
+		"Invalid": func(ctx context.Context) (interface{}, error) {
+			return account{}, nil
+		},
+	})
+	server.Register(jsonrpc.Methods{
+		"admin.Account": func(ctx context.Context) (interface{}, error) {
+			return jsonrpc.Accepted(account{Name: "amy", Balance: 100}), nil
+		},
+	})
+
+	resp := do(server, `[
+		{"id": 1, "method": "Account"},
+		{"id": 2, "method": "Invalid"}
+	]`)
+	assert.JSONEqual(t, resp.Body.String(), `[
+		{"id": 1, "result": {"name": "amy"}},
+		{"id": 2, "error": {"name": "internal_error", "message": "internal error", "retryable": true}}
+	]`)
+
+	// Values of the HTTP request's context are visible to Render.
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id": 1, "method": "admin.Account"}`))
+	req = req.WithContext(context.WithValue(req.Context(), adminKey{}, true))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Equal(t, w.Code, 202)
+	assert.JSONEqual(t, w.Body.String(), `{"id": 1, "result": {"name": "amy", "balance": 100}}`)
+
+	// So are the values added by middleware.
+	server = jsonrpc.New()
+	server.Use(func(next jsonrpc.Next) jsonrpc.Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			return next(context.WithValue(ctx, adminKey{}, true), params)
+		}
+	})
+	server.Register(jsonrpc.Methods{
+		"Account": func(ctx context.Context) (interface{}, error) {
+			return account{Name: "amy", Balance: 100}, nil
+		},
+	})
+	resp = do(server, `{"id": 1, "method": "Account"}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": {"name": "amy", "balance": 100}}`)
+}
+
+type profile struct {
+	Name, Email string
+}
+
+func (p profile) Render(ctx context.Context) (interface{}, error) {
+	if ctx.Value(adminKey{}) == nil {
+		return jsonrpc.M{"name": p.Name}, nil
+	}
+	return jsonrpc.M{"name": p.Name, "email": p.Email}, nil
+}
+
+func TestRendererSingleflight(t *testing.T) {
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	server := jsonrpc.New()
+	server.Use(jsonrpc.SingleflightMiddleware())
+	server.Register(jsonrpc.Methods{
+		"Profile": func(ctx context.Context) (interface{}, error) {
+			close(started)
+			<-release
+			return profile{Name: "amy", Email: "amy@example.com"}, nil
+		},
+	})
+	send := func(admin bool) string {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id": 1, "method": "Profile"}`))
+		if admin {
+			req = req.WithContext(context.WithValue(req.Context(), adminKey{}, true))
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	// The shared result is rendered for each caller, not only the first.
+	var adminBody, userBody string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		adminBody = send(true)
+	}()
+	<-started
+	go func() {
+		time.Sleep(20 * time.Millisecond) // let the call below join
+		close(release)
+	}()
+	userBody = send(false)
+	<-done
+	assert.JSONEqual(t, adminBody, `{"id": 1, "result": {"name": "amy", "email": "amy@example.com"}}`)
+	assert.JSONEqual(t, userBody, `{"id": 1, "result": {"name": "amy"}}`)
+}
@@ -0,0 +1,46 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Schema validates the raw JSON params of a method. It is typically
+// implemented as a thin adapter around a JSON Schema library.
+type Schema interface {
+	// Validate returns the problems found with params, or nil if params are
+	// valid.
+	Validate(params json.RawMessage) []FieldError
+}
+
+// FieldError describes a problem with a single params field.
+type FieldError struct {
+	// Field is the path to the offending field, e.g. "address.postcode".
+	Field string `json:"field"`
+
+	// Message is a human-readable description of the problem.
+	Message string `json:"message"`
+}
+
+// SchemaMiddleware returns middleware that validates the raw params of each
+// method against its schema, keyed by method name, before the method is
+// invoked. Methods without a schema are not validated. If validation fails,
+// an invalid_params error is returned with the field errors under "data".
+func SchemaMiddleware(schemas map[string]Schema) Middleware {
+	return func(next Next) Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			schema, ok := schemas[MethodFromContext(ctx)]
+			if !ok {
+				return next(ctx, params)
+			}
+			raw := RawParamsFromContext(ctx)
+			if raw == nil {
+				raw = json.RawMessage("null")
+			}
+			if errs := schema.Validate(raw); len(errs) > 0 {
+				return nil, InvalidParams("params failed validation").Data(errs)
+			}
+			return next(ctx, params)
+		}
+	}
+}
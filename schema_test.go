@@ -0,0 +1,112 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+// enumSchema is a minimal Schema that requires each listed field to be one of
+// a set of strings.
+type enumSchema map[string][]string
+
+func (s enumSchema) Validate(params json.RawMessage) []jsonrpc.FieldError {
+	var values map[string]interface{}
+	if err := json.Unmarshal(params, &values); err != nil {
+		return []jsonrpc.FieldError{{Message: "must be an object"}}
+	}
+	var errs []jsonrpc.FieldError
+	for field, allowed := range s {
+		ok := false
+		for _, a := range allowed {
+			if values[field] == a {
+				ok = true
+			}
+		}
+		if !ok {
+			errs = append(errs, jsonrpc.FieldError{Field: field, Message: "invalid value"})
+		}
+	}
+	return errs
+}
+
+func TestSchemaMiddleware(t *testing.T) {
+	type params struct {
+		Size string `json:"size"`
+	}
+	var calls int
+	server := jsonrpc.New()
+	server.Use(jsonrpc.SchemaMiddleware(map[string]jsonrpc.Schema{
+		"Order": enumSchema{"size": {"small", "large"}},
+	}))
+	server.Register(jsonrpc.Methods{
+		"Order": func(ctx context.Context, p params) (interface{}, error) {
+			calls++
+			return p.Size, nil
+		},
+		"Other": func(ctx context.Context, p params) (interface{}, error) {
+			calls++
+			return p.Size, nil
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "Order", "params": {"size": "small"}}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "small"}`)
+
+	resp = do(server, `{"id": 1, "method": "Order", "params": {"size": "huge"}}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {
+			"name": "invalid_params",
+			"message": "params failed validation",
+			"data": [{"field": "size", "message": "invalid value"}]
+		}
+	}`)
+
+	resp = do(server, `{"id": 1, "method": "Order", "params": {}}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {
+			"name": "invalid_params",
+			"message": "params failed validation",
+			"data": [{"field": "size", "message": "invalid value"}]
+		}
+	}`)
+
+	resp = do(server, `{"id": 1, "method": "Other", "params": {"size": "huge"}}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "huge"}`)
+	assert.Equal(t, calls, 2)
+}
+
+func TestSchemaMiddlewareNormalizedName(t *testing.T) {
+	type params struct {
+		Size string `json:"size"`
+	}
+	server := jsonrpc.New()
+	server.MethodNameNormalizer = strings.ToLower
+	server.Use(jsonrpc.SchemaMiddleware(map[string]jsonrpc.Schema{
+		"Order": enumSchema{"size": {"small", "large"}},
+	}))
+	server.Register(jsonrpc.Methods{
+		"Order": func(ctx context.Context, p params) (interface{}, error) {
+			return jsonrpc.MethodFromContext(ctx), nil
+		},
+	})
+
+	resp := do(server, `{"id": 1, "method": "order", "params": {"size": "small"}}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": "Order"}`)
+
+	resp = do(server, `{"id": 1, "method": "ORDER", "params": {"size": "huge"}}`)
+	assert.JSONEqual(t, resp.Body.String(), `{
+		"id": 1,
+		"error": {
+			"name": "invalid_params",
+			"message": "params failed validation",
+			"data": [{"field": "size", "message": "invalid value"}]
+		}
+	}`)
+}
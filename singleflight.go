@@ -0,0 +1,144 @@
+package jsonrpc
+
+import (
+	"context"
+	"reflect"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// SingleflightMiddleware returns middleware that coalesces concurrent,
+// identical calls: while a call to a method with given params is in flight,
+// further calls with the same method and raw params wait for it and share its
+// result and error, rather than invoking the method again. The warnings and
+// metadata added by the call are added to the response of every caller.
+//
+// Since results are shared between callers, they must not be modified by
+// middleware running outside of this one. Results that implement Renderer are
+// shared unrendered, and rendered with the context of each caller, so that
+// one caller never sees a result rendered for another. Each caller stops waiting when its
+// own context is done, without affecting the others. If the method panics,
+// every waiting caller panics with the same value.
+func SingleflightMiddleware() Middleware {
+	var g singleflight.Group
+	return func(next Next) Next {
+		return func(ctx context.Context, params interface{}) (interface{}, error) {
+			if inDryRun(ctx) {
+				return next(ctx, params)
+			}
+			key := MethodFromContext(ctx) + "\x00" + string(RawParamsFromContext(ctx))
+			if state := stateFromContext(ctx); state != nil {
+				state.renderLate = true
+			}
+			led := false
+			ch := g.DoChan(key, func() (interface{}, error) {
+				led = true
+				return runShared(ctx, next, params), nil
+			})
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case res := <-ch:
+				out := res.Val.(*sharedOutcome)
+				if out.panicked {
+					panic(out.panicVal)
+				}
+				if !led {
+					out.replay(ctx)
+				}
+				if out.err != nil {
+					return nil, out.err
+				}
+				return render(ctx, out.result)
+			}
+		}
+	}
+}
+
+// sharedOutcome is the outcome of a call shared by SingleflightMiddleware.
+// Panics are recovered and recorded, rather than left to singleflight, which
+// would crash the process.
+type sharedOutcome struct {
+	result   interface{}
+	err      error
+	panicked bool        // the call panicked with panicVal
+	panicVal interface{} // re-panicked to every caller
+	warnings []string    // added by the call
+	meta     M           // set by the call
+}
+
+// runShared calls next, recording its outcome, and the warnings and metadata
+// it adds to the state of ctx.
+func runShared(ctx context.Context, next Next, params interface{}) (out *sharedOutcome) {
+	out = &sharedOutcome{}
+	state := stateFromContext(ctx)
+	var (
+		warnings []string
+		meta     M
+	)
+	if state != nil {
+		warnings, meta = state.snapshot()
+	}
+	normal := false
+	defer func() {
+		if !normal {
+			out.panicked = true
+			out.panicVal = recover()
+		}
+		if state != nil {
+			out.warnings, out.meta = state.since(warnings, meta)
+		}
+	}()
+	out.result, out.err = next(ctx, params)
+	normal = true
+	return out
+}
+
+// replay adds the warnings and metadata of the shared call to the state of
+// ctx, as if the call had been made with it.
+func (o *sharedOutcome) replay(ctx context.Context) {
+	state := stateFromContext(ctx)
+	if state == nil {
+		return
+	}
+	for _, w := range o.warnings {
+		state.addWarning(w)
+	}
+	for k, v := range o.meta {
+		state.setMeta(k, v)
+	}
+}
+
+// snapshot returns copies of the warnings and metadata of s, to be compared
+// with them later by since.
+func (s *requestState) snapshot() ([]string, M) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meta := make(M, len(s.meta))
+	for k, v := range s.meta {
+		meta[k] = v
+	}
+	return s.warnings[:len(s.warnings):len(s.warnings)], meta
+}
+
+// since returns the warnings added to s, and the metadata set on it, since
+// the given snapshot was taken.
+func (s *requestState) since(warnings []string, meta M) ([]string, M) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var added []string
+	if len(s.warnings) > len(warnings) {
+		added = append(added, s.warnings[len(warnings):]...)
+	}
+	var changed M
+	for k, v := range s.meta {
+		if old, ok := meta[k]; ok && reflect.DeepEqual(old, v) {
+			continue
+		}
+		if changed == nil {
+			changed = make(M)
+		}
+		changed[k] = v
+	}
+	return added, changed
+}
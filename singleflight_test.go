@@ -0,0 +1,160 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestSingleflightMiddleware(t *testing.T) {
+	var (
+		calls   int32
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	server := jsonrpc.New()
+	server.Use(jsonrpc.SingleflightMiddleware())
+	server.Register(jsonrpc.Methods{
+		"Expensive": func(ctx context.Context, n int) (interface{}, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				close(started)
+				<-release
+			}
+			return n * 2, nil
+		},
+	})
+
+	const waiters = 5
+	var wg sync.WaitGroup
+	bodies := make([]string, waiters+1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		bodies[0] = do(server, `{"id": 1, "method": "Expensive", "params": 21}`).Body.String()
+	}()
+	<-started
+	for i := 1; i <= waiters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = do(server, `{"id": 1, "method": "Expensive", "params": 21}`).Body.String()
+		}(i)
+	}
+
+	// A call with different params is not coalesced.
+	resp := do(server, `{"id": 1, "method": "Expensive", "params": 1}`)
+	assert.JSONEqual(t, resp.Body.String(), `{"id": 1, "result": 2}`)
+
+	// Give the waiters time to join the in-flight call.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for _, body := range bodies {
+		assert.JSONEqual(t, body, `{"id": 1, "result": 42}`)
+	}
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(2))
+}
+
+func TestSingleflightMiddlewarePanic(t *testing.T) {
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	server := jsonrpc.New()
+	server.Use(jsonrpc.SingleflightMiddleware())
+	server.Register(jsonrpc.Methods{
+		"Broken": func(ctx context.Context) (interface{}, error) {
+			close(started)
+			<-release
+			panic("boom")
+		},
+	})
+
+	const callers = 3
+	var wg sync.WaitGroup
+	bodies := make([]string, callers)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		bodies[0] = do(server, `{"id": 1, "method": "Broken"}`).Body.String()
+	}()
+	<-started
+	for i := 1; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = do(server, `{"id": 1, "method": "Broken"}`).Body.String()
+		}(i)
+	}
+
+	// Give the waiters time to join the in-flight call.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for _, body := range bodies {
+		assert.JSONEqual(t, body, `{
+			"id": 1,
+			"error": {"name": "internal_error", "message": "internal error", "retryable": true}
+		}`)
+	}
+}
+
+func TestSingleflightMiddlewareWaiters(t *testing.T) {
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	server := jsonrpc.New()
+	server.Use(jsonrpc.SingleflightMiddleware())
+	server.Register(jsonrpc.Methods{
+		"Slow": func(ctx context.Context) (interface{}, error) {
+			close(started)
+			<-release
+			jsonrpc.AddWarning(ctx, "slow")
+			jsonrpc.SetResponseMeta(ctx, "source", "origin")
+			return "done", nil
+		},
+	})
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 2)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		bodies[0] = do(server, `{"id": 1, "method": "Slow"}`).Body.String()
+	}()
+	<-started
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		bodies[1] = do(server, `{"id": 2, "method": "Slow"}`).Body.String()
+	}()
+
+	// A waiter whose context ends stops waiting, leaving the call in flight.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id": 3, "method": "Slow"}`)).WithContext(ctx)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	var got struct {
+		Error struct{ Name string }
+	}
+	assert.Must(t, json.Unmarshal(resp.Body.Bytes(), &got))
+	assert.True(t, got.Error.Name != "")
+
+	// The others share the result, and each gets the warnings and metadata
+	// of the call.
+	close(release)
+	wg.Wait()
+	assert.JSONEqual(t, bodies[0], `{"id": 1, "result": "done", "warnings": ["slow"], "meta": {"source": "origin"}}`)
+	assert.JSONEqual(t, bodies[1], `{"id": 2, "result": "done", "warnings": ["slow"], "meta": {"source": "origin"}}`)
+}
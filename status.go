@@ -0,0 +1,76 @@
+package jsonrpc
+
+import "net/http"
+
+// ErrorStatusMode controls the HTTP status of single (non-batch) responses
+// that contain an error. Batch responses always have status 200.
+type ErrorStatusMode int
+
+const (
+	// AlwaysOK renders errors returned by methods with status 200, in the
+	// style of JSON-RPC. Malformed requests (invalid_request and parse_error)
+	// still have status 400.
+	AlwaysOK ErrorStatusMode = iota
+
+	// MapFromName derives the status from the error name, using
+	// Handler.ErrorStatuses and then the defaults below. Unknown names are
+	// assumed to be client errors, with status 400.
+	//
+	//	internal_error      500
+	//	invalid_params      400
+	//	invalid_request     400
+	//	method_not_found    404
+	//	not_found           404
+	//	parse_error         400
+	//	precondition_failed 412
+	//	rate_limited        429
+	//	request_cancelled   499
+	//	service_unavailable 503
+	//	timeout             504
+	//	unauthorized        401
+	//	upgrade_required    426
+	MapFromName
+)
+
+// statusClientClosedRequest is the non-standard status used by nginx, among
+// others, when the client closes the connection before a response is sent.
+const statusClientClosedRequest = 499
+
+var defaultErrorStatuses = map[string]int{
+	"internal_error":      http.StatusInternalServerError,
+	"invalid_params":      http.StatusBadRequest,
+	"invalid_request":     http.StatusBadRequest,
+	"method_not_found":    http.StatusNotFound,
+	"not_found":           http.StatusNotFound,
+	"parse_error":         http.StatusBadRequest,
+	"precondition_failed": http.StatusPreconditionFailed,
+	"rate_limited":        http.StatusTooManyRequests,
+	"request_cancelled":   statusClientClosedRequest,
+	"service_unavailable": http.StatusServiceUnavailable,
+	"timeout":             http.StatusGatewayTimeout,
+	"unauthorized":        http.StatusUnauthorized,
+	"upgrade_required":    http.StatusUpgradeRequired,
+}
+
+// errorStatus returns the HTTP status for a single response with err.
+func (h *Handler) errorStatus(err *RPCError) int {
+	if err.status != 0 {
+		return err.status
+	}
+	switch h.ErrorStatusMode {
+	case MapFromName:
+		if status, ok := h.ErrorStatuses[err.Name]; ok {
+			return status
+		}
+		if status, ok := defaultErrorStatuses[err.Name]; ok {
+			return status
+		}
+		return http.StatusBadRequest
+	default:
+		switch err.Name {
+		case "invalid_request", "parse_error":
+			return http.StatusBadRequest
+		}
+		return http.StatusOK
+	}
+}
@@ -0,0 +1,209 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ServeStdio serves requests read from r, writing responses to w, using the
+// Content-Length framing of the Language Server Protocol:
+//
+//	Content-Length: 52\r\n
+//	\r\n
+//	{"id": 1, "method": "Hello", "params": {"name": "Alice"}}
+//
+// Responses are written with the same framing. Streaming methods may emit
+// notifications, which are framed in the same way, unless they are called
+// in a batch.
+//
+// Results that are channels are collected into an array before being written.
+// Results that cannot be encoded are replaced with internal_error responses.
+// Nothing is written for a batch of only notifications.
+//
+// Since there is no HTTP request, RequestFromContext returns nil for methods
+// served this way.
+//
+// ServeStdio returns nil when r is exhausted, or ctx's error if ctx is done.
+// If a frame is malformed, a parse_error response is written and the framing
+// error is returned, since the stream can't be resynchronized.
+func (h *Handler) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	var (
+		br = bufio.NewReader(r)
+		mu sync.Mutex
+	)
+	maxFrameSize := h.MaxFrameSize
+	if maxFrameSize <= 0 {
+		maxFrameSize = defaultMaxFrameSize
+	}
+	write := func(v interface{}) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(b)); err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		body, err := readFrame(br, maxFrameSize)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var fe *frameError
+			if errors.As(err, &fe) {
+				_ = write(response{
+					Error: Error("parse_error", "invalid framing: %s", fe.msg),
+				})
+			}
+			return err
+		}
+
+		requests, batch, err := h.parseBody(body)
+		if err != nil {
+			if err := write(response{Error: translateError(err), ID: h.errorID(body)}); err != nil {
+				return err
+			}
+			continue
+		}
+		h.intercept(requests)
+
+		var (
+			notify      notifier
+			closeNotify = func() {}
+		)
+		if !batch {
+			notify, closeNotify = notifier(func(method string, params interface{}) error {
+				return write(h.newNotification(method, params))
+			}).closable()
+		}
+		responses := h.dispatch(ctx, nil, requests, batch, notify)
+		if !batch && isRecvChan(responses[0].Result) {
+			responses[0].Result = h.collectChan(ctx, responses[0].Result)
+		}
+		closeNotify() // before the response, so that no notification follows it
+		replaceUnencodable(responses)
+		if batch {
+			if answered := answered(responses); len(answered) > 0 {
+				err = write(answered)
+			}
+		} else {
+			err = write(responses[0])
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// replaceUnencodable replaces each of responses that cannot be encoded with an
+// internal_error response for the same id, so that a bad result doesn't end
+// the session.
+func replaceUnencodable(responses []*response) {
+	for i, resp := range responses {
+		if resp.noReply {
+			continue
+		}
+		if _, err := json.Marshal(resp); err != nil {
+			responses[i] = &response{
+				Error:    InternalError(err),
+				Warnings: resp.Warnings,
+				ID:       resp.ID,
+			}
+		}
+	}
+}
+
+// maxHeaderLine is the length limit of each frame header line, beyond which
+// the frame is rejected rather than buffered.
+const maxHeaderLine = 4096
+
+// defaultMaxFrameSize is the frame size limit used when Handler.MaxFrameSize
+// is not set.
+const defaultMaxFrameSize = 10 << 20
+
+// frameError indicates a malformed frame header.
+type frameError struct {
+	msg string
+}
+
+func (e *frameError) Error() string { return "jsonrpc: invalid framing: " + e.msg }
+
+// readFrame reads a single Content-Length framed message from r, of at most
+// max bytes. It returns io.EOF if r is exhausted before a frame starts.
+func readFrame(r *bufio.Reader, max int) ([]byte, error) {
+	length := -1
+	for first := true; ; first = false {
+		line, err := readHeaderLine(r)
+		if err != nil {
+			if err == io.EOF && first && line == "" {
+				return nil, io.EOF
+			}
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break // end of headers
+		}
+		i := strings.Index(line, ":")
+		if i == -1 {
+			return nil, &frameError{fmt.Sprintf("malformed header %q", line)}
+		}
+		name, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if strings.EqualFold(name, "Content-Length") {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, &frameError{fmt.Sprintf("invalid Content-Length %q", value)}
+			}
+			if n > max {
+				return nil, &frameError{fmt.Sprintf("Content-Length %d exceeds limit of %d", n, max)}
+			}
+			length = n
+		}
+	}
+	if length == -1 {
+		return nil, &frameError{"missing Content-Length"}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return body, nil
+}
+
+// readHeaderLine reads a line of at most maxHeaderLine bytes from r.
+func readHeaderLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxHeaderLine {
+			return "", &frameError{fmt.Sprintf("header line exceeds limit of %d bytes", maxHeaderLine)}
+		}
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return string(line), err
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package jsonrpc_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func frame(body string) string {
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func TestServeStdio(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Upper": func(ctx context.Context, s string) (interface{}, error) {
+			return strings.ToUpper(s), nil
+		},
+		"Count": func(ctx context.Context, n int, emit jsonrpc.Emit) (interface{}, error) {
+			for i := 1; i <= n; i++ {
+				if err := emit(i); err != nil {
+					return nil, err
+				}
+			}
+			return "done", nil
+		},
+		"Func": func(ctx context.Context) (interface{}, error) {
+			return func() {}, nil
+		},
+		"Letters": func(ctx context.Context) (interface{}, error) {
+			ch := make(chan string, 2)
+			ch <- "a"
+			ch <- "b"
+			close(ch)
+			return ch, nil
+		},
+	})
+
+	t.Run("requests", func(t *testing.T) {
+		in := frame(`{"id": 1, "method": "Upper", "params": "a"}`) +
+			"Content-Type: application/vscode-jsonrpc; charset=utf-8\r\n" +
+			frame(`[{"id": 2, "method": "Upper", "params": "b"}, {"method": "Upper", "params": "x"}, {"id": 3, "method": "Upper", "params": "c"}]`) +
+			frame(`[{"method": "Upper", "params": "y"}]`) +
+			frame(`{"id": 4,}`) +
+			frame(`{"id": 5, "method": "Count", "params": 2}`) +
+			frame(`{"id": 6, "method": "Letters"}`)
+		var out bytes.Buffer
+		assert.Must(t, server.ServeStdio(context.Background(), strings.NewReader(in), &out))
+		assert.Equal(t, out.String(), ""+
+			frame(`{"result":"A","id":1}`)+
+			frame(`[{"result":"B","id":2},{"result":"C","id":3}]`)+
+			frame(`{"error":{"name":"parse_error","message":"cannot parse request: offset 10: invalid character '}' looking for beginning of object key string"},"id":null}`)+
+			frame(`{"method":"Count","params":1}`)+
+			frame(`{"method":"Count","params":2}`)+
+			frame(`{"result":"done","id":5}`)+
+			frame(`{"result":["a","b"],"id":6}`))
+	})
+
+	t.Run("notification ids", func(t *testing.T) {
+		server.IDGenerator = &jsonrpc.CounterIDGenerator{}
+		defer func() { server.IDGenerator = nil }()
+		in := frame(`{"id": 1, "method": "Count", "params": 1}`)
+		var out bytes.Buffer
+		assert.Must(t, server.ServeStdio(context.Background(), strings.NewReader(in), &out))
+		assert.Equal(t, out.String(), ""+
+			frame(`{"method":"Count","params":1,"id":"1"}`)+
+			frame(`{"result":"done","id":1}`))
+	})
+
+	t.Run("malformed framing", func(t *testing.T) {
+		in := "Content-Length: abc\r\n\r\n{}"
+		var out bytes.Buffer
+		err := server.ServeStdio(context.Background(), strings.NewReader(in), &out)
+		assert.Equal(t, err.Error(), `jsonrpc: invalid framing: invalid Content-Length "abc"`)
+		assert.Equal(t, out.String(), frame(
+			`{"error":{"name":"parse_error","message":"invalid framing: invalid Content-Length \"abc\""},"id":null}`,
+		))
+	})
+
+	t.Run("unencodable result", func(t *testing.T) {
+		in := frame(`{"id": 1, "method": "Func"}`) +
+			frame(`[{"id": 2, "method": "Func"}, {"id": 3, "method": "Upper", "params": "c"}]`) +
+			frame(`{"id": 4, "method": "Upper", "params": "d"}`)
+		var out bytes.Buffer
+		assert.Must(t, server.ServeStdio(context.Background(), strings.NewReader(in), &out))
+		got := out.String()
+		assert.Equal(t, strings.Count(got, `"name":"internal_error"`), 2)
+		assert.True(t, strings.Contains(got, `},"id":1}`))
+		assert.True(t, strings.Contains(got, `},"id":2},{"result":"C","id":3}]`))
+		assert.True(t, strings.HasSuffix(got, frame(`{"result":"D","id":4}`)))
+	})
+
+	t.Run("long header line", func(t *testing.T) {
+		in := "X-Padding: " + strings.Repeat("a", 5000) + "\r\n" + frame(`{}`)
+		var out bytes.Buffer
+		err := server.ServeStdio(context.Background(), strings.NewReader(in), &out)
+		assert.Equal(t, err.Error(), "jsonrpc: invalid framing: header line exceeds limit of 4096 bytes")
+		assert.Equal(t, out.String(), frame(
+			`{"error":{"name":"parse_error","message":"invalid framing: header line exceeds limit of 4096 bytes"},"id":null}`,
+		))
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		in := "Content-Length: 10\r\n\r\n{}"
+		err := server.ServeStdio(context.Background(), strings.NewReader(in), &bytes.Buffer{})
+		assert.Equal(t, err.Error(), "unexpected EOF")
+	})
+
+	t.Run("oversized", func(t *testing.T) {
+		server.MaxFrameSize = 16
+		defer func() { server.MaxFrameSize = 0 }()
+		in := "Content-Length: 1000000000\r\n\r\n{}"
+		var out bytes.Buffer
+		err := server.ServeStdio(context.Background(), strings.NewReader(in), &out)
+		assert.Equal(t, err.Error(), "jsonrpc: invalid framing: Content-Length 1000000000 exceeds limit of 16")
+		assert.Equal(t, out.String(), frame(
+			`{"error":{"name":"parse_error","message":"invalid framing: Content-Length 1000000000 exceeds limit of 16"},"id":null}`,
+		))
+	})
+}
@@ -0,0 +1,60 @@
+package jsonrpc
+
+import "sort"
+
+// maxSuggestionDistance is the maximum edit distance between a requested
+// method name and a registered one for the latter to be suggested.
+const maxSuggestionDistance = 3
+
+// suggestMethod returns the registered method name closest to name, or "" if
+// none is close enough.
+func (h *Handler) suggestMethod(name string) string {
+	// Sort candidates so that ties are broken deterministically.
+	h.mu.RLock()
+	candidates := make([]string, 0, len(h.methods))
+	for _, m := range h.methods {
+		candidates = append(candidates, m.Name)
+	}
+	h.mu.RUnlock()
+	sort.Strings(candidates)
+
+	best, bestDist := "", maxSuggestionDistance+1
+	for _, candidate := range candidates {
+		if d := levenshtein(name, candidate); d < bestDist {
+			best, bestDist = candidate, d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
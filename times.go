@@ -0,0 +1,40 @@
+package jsonrpc
+
+import "time"
+
+// encodeTimes returns v with every time.Time replaced by the result of fn.
+// time.Time values are replaced when they are the result itself, or are
+// nested within M, map[string]interface{} or []interface{} values. Maps and
+// slices are copied rather than modified in place. Struct fields are left
+// untouched, since their type cannot be changed.
+func encodeTimes(v interface{}, fn func(time.Time) interface{}) interface{} {
+	switch v := v.(type) {
+	case time.Time:
+		return fn(v)
+	case *time.Time:
+		if v == nil {
+			return v
+		}
+		return fn(*v)
+	case M:
+		return M(encodeTimesMap(v, fn))
+	case map[string]interface{}:
+		return encodeTimesMap(v, fn)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, elem := range v {
+			result[i] = encodeTimes(elem, fn)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+func encodeTimesMap(m map[string]interface{}, fn func(time.Time) interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(m))
+	for k, elem := range m {
+		result[k] = encodeTimes(elem, fn)
+	}
+	return result
+}
@@ -0,0 +1,32 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// traceIDHeader is the header the trace id is read from, and echoed in.
+const traceIDHeader = "X-Request-ID"
+
+// TraceIDFromContext extracts the HTTP-level correlation id of the current
+// request from the given context.Context. Unlike the id returned by
+// RequestIDFromContext, it is shared by all requests in a batch. It is taken
+// from the X-Request-ID header of the HTTP request, or generated if the header
+// is absent, and is echoed in the X-Request-ID header of the response. It
+// returns "" if ctx did not originate from Handler.ServeHTTP.
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKeyTraceID).(string)
+	return id
+}
+
+// traceID returns the trace id of r, generating one if it has none.
+func traceID(r *http.Request) string {
+	if id := r.Header.Get(traceIDHeader); id != "" {
+		return id
+	}
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
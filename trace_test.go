@@ -0,0 +1,56 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestTraceID(t *testing.T) {
+	var got []string
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Trace": func(ctx context.Context) (interface{}, error) {
+			got = append(got, jsonrpc.TraceIDFromContext(ctx))
+			return nil, nil
+		},
+	})
+
+	t.Run("provided", func(t *testing.T) {
+		got = nil
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[
+			{"id": 1, "method": "Trace"},
+			{"id": 2, "method": "Trace"}
+		]`))
+		req.Header.Set("X-Request-ID", "abc123")
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, req)
+		assert.Equal(t, resp.Header().Get("X-Request-ID"), "abc123")
+		assert.Equal(t, got, []string{"abc123", "abc123"})
+	})
+
+	t.Run("generated", func(t *testing.T) {
+		got = nil
+		resp := do(server, `{"id": 1, "method": "Trace"}`)
+		assert.Equal(t, len(got), 1)
+		assert.Equal(t, len(got[0]), 32)
+		assert.Equal(t, resp.Header().Get("X-Request-ID"), got[0])
+
+		resp = do(server, `{"id": 1, "method": "Trace"}`)
+		assert.Equal(t, resp.Header().Get("X-Request-ID") != got[0], true)
+	})
+
+	t.Run("parse error", func(t *testing.T) {
+		resp := do(server, `{`)
+		assert.Equal(t, len(resp.Header().Get("X-Request-ID")), 32)
+	})
+
+	t.Run("outside handler", func(t *testing.T) {
+		assert.Equal(t, jsonrpc.TraceIDFromContext(context.Background()), "")
+	})
+}
@@ -0,0 +1,292 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// GenerateTypeScript writes TypeScript definitions for the registered methods
+// to w. An interface is emitted for every named struct type reachable from the
+// methods' params, followed by a Client interface with one function per
+// method.
+//
+// Result types are not known until a method is called, so every method
+// returns Promise<unknown>. Method and field names that are not valid
+// identifiers, such as "admin.Get", are quoted. Types from different packages
+// that share a name are prefixed with their package names, and numbered if
+// that is not enough to tell them apart.
+//
+// Example output:
+//
+//	export interface HelloParams {
+//		name: string;
+//	}
+//
+//	export interface Client {
+//		Hello(params: HelloParams): Promise<unknown>;
+//	}
+//
+func (h *Handler) GenerateTypeScript(w io.Writer) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	methods := make([]method, 0, len(h.methods))
+	for _, m := range h.methods {
+		methods = append(methods, m)
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i].Name < methods[j].Name
+	})
+
+	g := &tsGenerator{seen: make(map[reflect.Type]string)}
+	for _, m := range methods {
+		if m.paramsType != nil {
+			g.collect(m.paramsType, make(map[reflect.Type]bool))
+		}
+	}
+	g.assignNames()
+
+	var client bytes.Buffer
+	client.WriteString("export interface Client {\n")
+	for _, m := range methods {
+		if m.paramsType == nil {
+			fmt.Fprintf(&client, "\t%s(): Promise<unknown>;\n", tsName(m.Name))
+			continue
+		}
+		fmt.Fprintf(&client, "\t%s(params: %s): Promise<unknown>;\n", tsName(m.Name), g.typeOf(m.paramsType))
+	}
+	client.WriteString("}\n")
+
+	var out bytes.Buffer
+	for _, decl := range g.decls {
+		out.WriteString(decl)
+		out.WriteString("\n")
+	}
+	out.Write(client.Bytes())
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// tsGenerator accumulates TypeScript declarations for named Go types.
+type tsGenerator struct {
+	decls []string
+	seen  map[reflect.Type]string // types declared so far
+	types []reflect.Type          // named struct types, in order of discovery
+	names map[reflect.Type]string // TypeScript names of types
+}
+
+// collect records the named struct types reachable from t, so that their
+// names can be assigned before any are declared.
+func (g *tsGenerator) collect(t reflect.Type, visited map[reflect.Type]bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if visited[t] || t == typeTimeTime ||
+		t.Implements(typeJSONMarshaler) || reflect.PtrTo(t).Implements(typeJSONMarshaler) {
+		return
+	}
+	visited[t] = true
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		g.collect(t.Elem(), visited)
+	case reflect.Struct:
+		if t.Name() != "" {
+			g.types = append(g.types, t)
+		}
+		g.collectFields(t, visited)
+	}
+}
+
+// collectFields is like collect, for the fields of struct type t, following
+// the same rules as fields.
+func (g *tsGenerator) collectFields(t reflect.Type, visited map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && strings.Split(tag, ",")[0] == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.collectFields(ft, visited)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		g.collect(f.Type, visited)
+	}
+}
+
+// assignNames names the collected types after their Go names, qualifying
+// those that collide.
+func (g *tsGenerator) assignNames() {
+	byName := make(map[string][]reflect.Type)
+	for _, t := range g.types {
+		byName[t.Name()] = append(byName[t.Name()], t)
+	}
+	g.names = make(map[reflect.Type]string, len(g.types))
+	used := make(map[string]bool)
+	for _, t := range g.types {
+		name := t.Name()
+		if len(byName[name]) > 1 {
+			name = tsPackagePrefix(t.PkgPath()) + name
+		}
+		base := name
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s%d", base, i)
+		}
+		used[name] = true
+		g.names[t] = name
+	}
+}
+
+// tsPackagePrefix returns the last element of pkgPath in PascalCase, e.g.
+// "Mail" for "net/mail".
+func tsPackagePrefix(pkgPath string) string {
+	if i := strings.LastIndex(pkgPath, "/"); i != -1 {
+		pkgPath = pkgPath[i+1:]
+	}
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(pkgPath, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
+
+// tsName returns name as a TypeScript property name, quoted if it is not a
+// valid identifier.
+func tsName(name string) string {
+	for i, r := range name {
+		if r == '_' || r == '$' || unicode.IsLetter(r) || i > 0 && unicode.IsDigit(r) {
+			continue
+		}
+		b, _ := json.Marshal(name)
+		return string(b)
+	}
+	if name == "" {
+		return `""`
+	}
+	return name
+}
+
+var typeJSONMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// typeOf returns the TypeScript type expression for t, emitting declarations
+// for any named struct types it encounters.
+func (g *tsGenerator) typeOf(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case typeTimeTime:
+		return "string"
+	case typeTimeDuration:
+		return "number"
+	}
+	if t.Implements(typeJSONMarshaler) || reflect.PtrTo(t).Implements(typeJSONMarshaler) {
+		return "unknown" // custom encoding
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string" // base64 encoded
+		}
+		return g.typeOf(t.Elem()) + "[]"
+	case reflect.Array:
+		return g.typeOf(t.Elem()) + "[]"
+	case reflect.Map:
+		return "{ [key: string]: " + g.typeOf(t.Elem()) + " }"
+	case reflect.Struct:
+		if t.Name() == "" {
+			return "{ " + strings.Join(g.fields(t), " ") + " }"
+		}
+		if name, ok := g.seen[t]; ok {
+			return name
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = t.Name()
+		}
+		g.seen[t] = name // record before recursing, for self-referential types
+
+		var decl bytes.Buffer
+		fmt.Fprintf(&decl, "export interface %s {\n", name)
+		for _, f := range g.fields(t) {
+			fmt.Fprintf(&decl, "\t%s\n", f)
+		}
+		decl.WriteString("}\n")
+		g.decls = append(g.decls, decl.String())
+		return name
+	default:
+		return "unknown"
+	}
+}
+
+// fields returns the TypeScript field declarations for struct type t,
+// following the same rules as encoding/json.
+func (g *tsGenerator) fields(t reflect.Type) []string {
+	var result []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i != -1 {
+			name, opts = tag[:i], tag[i:]
+		}
+
+		// Flatten embedded structs without an explicit name.
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				result = append(result, g.fields(ft)...)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		typ := g.typeOf(f.Type)
+		if strings.Contains(opts, ",string") {
+			typ = "string"
+		}
+		optional := ""
+		if strings.Contains(opts, ",omitempty") {
+			optional = "?"
+		}
+		result = append(result, fmt.Sprintf("%s%s: %s;", tsName(name), optional, typ))
+	}
+	return result
+}
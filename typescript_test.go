@@ -0,0 +1,115 @@
+package jsonrpc_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io/ioutil"
+	"net/mail"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+func TestGenerateTypeScript(t *testing.T) {
+	type Address struct {
+		Line1    string `json:"line1"`
+		Postcode string `json:"postcode,omitempty"`
+	}
+	type CreateUserParams struct {
+		Name      string            `json:"name"`
+		Age       int               `json:"age"`
+		Admin     bool              `json:"admin,omitempty"`
+		Tags      []string          `json:"tags"`
+		Address   *Address          `json:"address"`
+		Meta      map[string]int    `json:"meta"`
+		CreatedAt time.Time         `json:"created_at"`
+		Extra     interface{}       `json:"extra"`
+		Internal  string            `json:"-"`
+		Labels    map[string]string `json:"labels,omitempty"`
+		private   string
+	}
+
+	h := jsonrpc.New()
+	h.Register(jsonrpc.Methods{
+		"CreateUser": func(ctx context.Context, params *CreateUserParams) (interface{}, error) {
+			return nil, nil
+		},
+		"Upper": func(ctx context.Context, s string) (interface{}, error) {
+			return nil, nil
+		},
+		"Now": func(ctx context.Context) (interface{}, error) {
+			return nil, nil
+		},
+	})
+
+	var buf bytes.Buffer
+	assert.Must(t, h.GenerateTypeScript(&buf))
+	assert.Equal(t, buf.String(), `export interface Address {
+	line1: string;
+	postcode?: string;
+}
+
+export interface CreateUserParams {
+	name: string;
+	age: number;
+	admin?: boolean;
+	tags: string[];
+	address: Address;
+	meta: { [key: string]: number };
+	created_at: string;
+	extra: unknown;
+	labels?: { [key: string]: string };
+}
+
+export interface Client {
+	CreateUser(params: CreateUserParams): Promise<unknown>;
+	Now(): Promise<unknown>;
+	Upper(params: string): Promise<unknown>;
+}
+`)
+}
+
+func TestGenerateTypeScriptGolden(t *testing.T) {
+	type Address struct {
+		Street string `json:"street"`
+	}
+	type CreateOrderParams struct {
+		Delivery Address       `json:"delivery"`
+		Contact  *mail.Address `json:"contact"`
+		ItemIDs  []int         `json:"item-ids"`
+	}
+	billing := func() interface{} {
+		type Address struct {
+			Postcode string `json:"postcode"`
+		}
+		return func(ctx context.Context, params Address) (interface{}, error) {
+			return nil, nil
+		}
+	}
+
+	h := jsonrpc.New()
+	h.Register(jsonrpc.Methods{
+		"orders.Create": func(ctx context.Context, params *CreateOrderParams) (interface{}, error) {
+			return nil, nil
+		},
+		"billing.SetAddress": billing(),
+		"admin.Ping": func(ctx context.Context) (interface{}, error) {
+			return nil, nil
+		},
+	})
+
+	var buf bytes.Buffer
+	assert.Must(t, h.GenerateTypeScript(&buf))
+	const golden = "testdata/typescript.golden"
+	if *update {
+		assert.Must(t, ioutil.WriteFile(golden, buf.Bytes(), 0644))
+	}
+	want, err := ioutil.ReadFile(golden)
+	assert.Must(t, err)
+	assert.Equal(t, buf.String(), string(want))
+}
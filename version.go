@@ -0,0 +1,43 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+)
+
+// VersionMode controls how the optional "jsonrpc" version member of requests
+// is validated. Requests without the member are always accepted.
+type VersionMode int
+
+const (
+	// IgnoreVersion accepts any version.
+	IgnoreVersion VersionMode = iota
+
+	// WarnVersion accepts any version, but adds a warning to the response if
+	// it is not "2.0".
+	WarnVersion
+
+	// RejectVersion rejects requests whose version is not "2.0" with an
+	// invalid_request error.
+	RejectVersion
+)
+
+// checkVersion validates the version of req according to h.VersionMode. The
+// version is kept raw until then, so that a malformed one, such as the number
+// 2.0, is only an error if the mode requires it.
+func (h *Handler) checkVersion(ctx context.Context, req *request) error {
+	if h.VersionMode == IgnoreVersion {
+		return nil
+	}
+	version := string(bytes.TrimSpace(req.Version))
+	if version == "" || version == "null" || version == `"2.0"` {
+		return nil
+	}
+	switch h.VersionMode {
+	case WarnVersion:
+		AddWarning(ctx, "unsupported jsonrpc version: %s", version)
+	case RejectVersion:
+		return InvalidRequest("unsupported jsonrpc version: %s", version)
+	}
+	return nil
+}
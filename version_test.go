@@ -0,0 +1,53 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrpc-go"
+)
+
+func TestVersionMode(t *testing.T) {
+	server := jsonrpc.New()
+	server.Register(jsonrpc.Methods{
+		"Ping": func(ctx context.Context) (interface{}, error) {
+			return "pong", nil
+		},
+	})
+	body := `[
+		{"jsonrpc": "2.0", "id": 1, "method": "Ping"},
+		{"jsonrpc": "1.0", "id": 2, "method": "Ping"},
+		{"id": 3, "method": "Ping"},
+		{"jsonrpc": 2.0, "id": 4, "method": "Ping"}
+	]`
+
+	tests := []struct {
+		mode jsonrpc.VersionMode
+		resp string
+	}{
+		{jsonrpc.IgnoreVersion, `[
+			{"id": 1, "result": "pong"},
+			{"id": 2, "result": "pong"},
+			{"id": 3, "result": "pong"},
+			{"id": 4, "result": "pong"}
+		]`},
+		{jsonrpc.WarnVersion, `[
+			{"id": 1, "result": "pong"},
+			{"id": 2, "result": "pong", "warnings": ["unsupported jsonrpc version: \"1.0\""]},
+			{"id": 3, "result": "pong"},
+			{"id": 4, "result": "pong", "warnings": ["unsupported jsonrpc version: 2.0"]}
+		]`},
+		{jsonrpc.RejectVersion, `[
+			{"id": 1, "result": "pong"},
+			{"id": 2, "error": {"name": "invalid_request", "message": "unsupported jsonrpc version: \"1.0\""}},
+			{"id": 3, "result": "pong"},
+			{"id": 4, "error": {"name": "invalid_request", "message": "unsupported jsonrpc version: 2.0"}}
+		]`},
+	}
+	for _, tt := range tests {
+		server.VersionMode = tt.mode
+		resp := do(server, body)
+		assert.JSONEqual(t, resp.Body.String(), tt.resp)
+	}
+}